@@ -1,14 +1,14 @@
 package yaks
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
 
 // Admin represents the admin interface to operate on Yaks.
 type Admin struct {
-	w      *Workspace
-	yaksid string
+	w *Workspace
 }
 
 //
@@ -17,46 +17,57 @@ type Admin struct {
 
 // AddBackend adds a backend in the connected Yaks
 func (a *Admin) AddBackend(beid string, properties Properties) error {
-	return a.AddBackendAt(beid, properties, a.yaksid)
+	return a.AddBackendAt(beid, properties, a.w.yaks.getYaksid())
 }
 
 // AddBackendAt adds a backend in the specified Yaks
 func (a *Admin) AddBackendAt(beid string, properties Properties, yaks string) error {
 	path, err := NewPath(fmt.Sprintf("/@/%s/plugins/yaks/backend/%s", yaks, beid))
 	if err != nil {
-		return &YError{"Invalid backend id: " + beid, err}
+		return &YError{Op: "AddBackend", msg: "invalid backend id: " + beid, kind: ErrInvalidPath, cause: err}
 	}
 	return a.w.Put(path, NewPropertiesValue(properties))
 }
 
 // GetBackend gets a backend's properties from the connected Yaks.
+// If the backend doesn't exist, an error matching ErrNotFound is returned.
 func (a *Admin) GetBackend(beid string) (Properties, error) {
-	return a.GetBackendAt(beid, a.yaksid)
+	return a.GetBackendAt(beid, a.w.yaks.getYaksid())
 }
 
 // GetBackendAt gets a backend's properties from the specified Yaks.
+// If the backend doesn't exist, an error matching ErrNotFound is returned.
 func (a *Admin) GetBackendAt(beid string, yaks string) (Properties, error) {
 	selector, err := NewSelector(fmt.Sprintf("/@/%s/plugins/yaks/backend/%s", yaks, beid))
 	if err != nil {
-		return nil, &YError{"Invalid backend id: " + beid, err}
+		return nil, &YError{Op: "GetBackend", msg: "invalid backend id: " + beid, kind: ErrInvalidSelector, cause: err}
+	}
+	pvs, err := a.w.GetContext(context.Background(), selector)
+	if err != nil {
+		return nil, err
 	}
-	pvs := a.w.Get(selector)
 	if len(pvs) == 0 {
-		return nil, nil
+		return nil, &YError{Op: "GetBackend", Path: selector.ToString(), kind: ErrNotFound}
 	}
 	return propertiesOfValue(pvs[0].Value()), nil
 }
 
 // GetBackends gets all the backends from the connected Yaks.
 func (a *Admin) GetBackends() (map[string]Properties, error) {
-	return a.GetBackendsAt(a.yaksid)
+	return a.GetBackendsAt(a.w.yaks.getYaksid())
 }
 
 // GetBackendsAt gets all the backends from the specified Yaks.
 func (a *Admin) GetBackendsAt(yaks string) (map[string]Properties, error) {
 	sel := fmt.Sprintf("/@/%s/plugins/yaks/backend/*", yaks)
-	selector, _ := NewSelector(sel)
-	pvs := a.w.Get(selector)
+	selector, err := NewSelector(sel)
+	if err != nil {
+		return nil, &YError{Op: "GetBackends", msg: "invalid Yaks id: " + yaks, kind: ErrInvalidSelector, cause: err}
+	}
+	pvs, err := a.w.GetContext(context.Background(), selector)
+	if err != nil {
+		return nil, err
+	}
 	result := make(map[string]Properties)
 	for _, pv := range pvs {
 		beid := pv.Path().ToString()[len(sel)-1:]
@@ -67,14 +78,14 @@ func (a *Admin) GetBackendsAt(yaks string) (map[string]Properties, error) {
 
 // RemoveBackend removes a backend from the connected Yaks.
 func (a *Admin) RemoveBackend(beid string) error {
-	return a.RemoveBackendAt(beid, a.yaksid)
+	return a.RemoveBackendAt(beid, a.w.yaks.getYaksid())
 }
 
 // RemoveBackendAt removes a backend from the specified Yaks.
 func (a *Admin) RemoveBackendAt(beid string, yaks string) error {
 	path, err := NewPath(fmt.Sprintf("/@/%s/plugins/yaks/backend/%s", yaks, beid))
 	if err != nil {
-		return &YError{"Invalid backend id: " + beid, err}
+		return &YError{Op: "RemoveBackend", msg: "invalid backend id: " + beid, kind: ErrInvalidPath, cause: err}
 	}
 	return a.w.Remove(path)
 }
@@ -85,7 +96,7 @@ func (a *Admin) RemoveBackendAt(beid string, yaks string) error {
 
 // AddStorage adds a storage in the connected Yaks, using an automatically chosen backend.
 func (a *Admin) AddStorage(stid string, properties Properties) error {
-	return a.AddStorageOnBackendAt(stid, properties, "auto", a.yaksid)
+	return a.AddStorageOnBackendAt(stid, properties, "auto", a.w.yaks.getYaksid())
 }
 
 // AddStorageAt adds a storage in the specified Yaks, using an automatically chosen backend.
@@ -95,49 +106,54 @@ func (a *Admin) AddStorageAt(stid string, properties Properties, yaks string) er
 
 // AddStorageOnBackend adds a storage in the connected Yaks, using the specified backend.
 func (a *Admin) AddStorageOnBackend(stid string, properties Properties, backend string) error {
-	return a.AddStorageOnBackendAt(stid, properties, backend, a.yaksid)
+	return a.AddStorageOnBackendAt(stid, properties, backend, a.w.yaks.getYaksid())
 }
 
 // AddStorageOnBackendAt adds a storage in the specified Yaks, using the specified backend.
 func (a *Admin) AddStorageOnBackendAt(stid string, properties Properties, backend string, yaks string) error {
 	path, err := NewPath(fmt.Sprintf("/@/%s/plugins/yaks/backend/%s/storage/%s", yaks, backend, stid))
 	if err != nil {
-		return &YError{"Invalid backend or storage id in path: " + path.ToString(), err}
+		return &YError{Op: "AddStorage", msg: "invalid backend or storage id: " + backend + ", " + stid, kind: ErrInvalidPath, cause: err}
 	}
 	return a.w.Put(path, NewPropertiesValue(properties))
 }
 
 // GetStorage gets a storage's properties from the connected Yaks.
+// If the storage doesn't exist, an error matching ErrNotFound is returned.
 func (a *Admin) GetStorage(stid string) (Properties, error) {
-	return a.GetStorageAt(stid, a.yaksid)
+	return a.GetStorageAt(stid, a.w.yaks.getYaksid())
 }
 
 // GetStorageAt gets a storage's properties from the specified Yaks.
+// If the storage doesn't exist, an error matching ErrNotFound is returned.
 func (a *Admin) GetStorageAt(stid string, yaks string) (Properties, error) {
 	selector, err := NewSelector(fmt.Sprintf("/@/%s/plugins/yaks/backend/*/storage/%s", yaks, stid))
 	if err != nil {
-		return nil, &YError{"Invalid storage id: " + stid, err}
+		return nil, &YError{Op: "GetStorage", msg: "invalid storage id: " + stid, kind: ErrInvalidSelector, cause: err}
+	}
+	pvs, err := a.w.GetContext(context.Background(), selector)
+	if err != nil {
+		return nil, err
 	}
-	pvs := a.w.Get(selector)
 	if len(pvs) == 0 {
-		return nil, nil
+		return nil, &YError{Op: "GetStorage", Path: selector.ToString(), kind: ErrNotFound}
 	}
 	return propertiesOfValue(pvs[0].Value()), nil
 }
 
 // GetStorages gets all the storages from the connected Yaks.
 func (a *Admin) GetStorages() (map[string]Properties, error) {
-	return a.GetStoragesFromBackendAt("*", a.yaksid)
+	return a.GetStoragesFromBackendAt("*", a.w.yaks.getYaksid())
 }
 
 // GetStoragesAt gets all the storages from the specified Yaks.
 func (a *Admin) GetStoragesAt(yaks string) (map[string]Properties, error) {
-	return a.GetStoragesFromBackendAt("*", a.yaksid)
+	return a.GetStoragesFromBackendAt("*", a.w.yaks.getYaksid())
 }
 
 // GetStoragesFromBackend gets all the storages from the specified backend within the connected Yaks.
 func (a *Admin) GetStoragesFromBackend(backend string) (map[string]Properties, error) {
-	return a.GetStoragesFromBackendAt(backend, a.yaksid)
+	return a.GetStoragesFromBackendAt(backend, a.w.yaks.getYaksid())
 }
 
 // GetStoragesFromBackendAt gets all the storages from the specified backend within the specified Yaks.
@@ -145,9 +161,12 @@ func (a *Admin) GetStoragesFromBackendAt(backend string, yaks string) (map[strin
 	sel := fmt.Sprintf("/@/%s/plugins/yaks/backend/%s/storage/*", yaks, backend)
 	selector, err := NewSelector(sel)
 	if err != nil {
-		return nil, &YError{"Invalid backend id: " + backend, err}
+		return nil, &YError{Op: "GetStorages", msg: "invalid backend id: " + backend, kind: ErrInvalidSelector, cause: err}
+	}
+	pvs, err := a.w.GetContext(context.Background(), selector)
+	if err != nil {
+		return nil, err
 	}
-	pvs := a.w.Get(selector)
 	result := make(map[string]Properties)
 	for _, pv := range pvs {
 		stPath := pv.Path().ToString()
@@ -159,21 +178,22 @@ func (a *Admin) GetStoragesFromBackendAt(backend string, yaks string) (map[strin
 
 // RemoveStorage removes a storage from the connected Yaks.
 func (a *Admin) RemoveStorage(stid string) error {
-	return a.RemoveStorageAt(stid, a.yaksid)
+	return a.RemoveStorageAt(stid, a.w.yaks.getYaksid())
 }
 
 // RemoveStorageAt removes a storage from the specified Yaks.
 func (a *Admin) RemoveStorageAt(stid string, yaks string) error {
 	selector, err := NewSelector(fmt.Sprintf("/@/%s/plugins/yaks/backend/*/storage/%s", yaks, stid))
 	if err != nil {
-		return &YError{"Invalid storage id: " + stid, err}
+		return &YError{Op: "RemoveStorage", msg: "invalid storage id: " + stid, kind: ErrInvalidSelector, cause: err}
 	}
-	pvs := a.w.Get(selector)
-	for _, pv := range pvs {
-		p := pv.Path()
-		err := a.w.Remove(p)
-		if err != nil {
-			return err
+	results, err := a.w.RemoveAll(selector, nil)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return r.Err
 		}
 	}
 	return nil
@@ -0,0 +1,199 @@
+// Package aggregate computes statistics (count, min, max, mean, sum, last) over Yaks entries,
+// typically the time series returned by Workspace.GetSeries or Workspace.GetRange.
+// The numeric values are extracted from the entries by an Extractor: Number for values
+// that are numbers themselves, or Field for a numeric field of JSON or PROPERTIES values.
+package aggregate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	yaks "github.com/atolab/yaks-go"
+)
+
+// ErrNoValue is returned by the AggregateFuncs when no numeric value can be extracted from the entries
+var ErrNoValue = errors.New("no numeric value to aggregate")
+
+// Extractor extracts a numeric value from a Value, returning an error if it has none
+type Extractor func(v yaks.Value) (float64, error)
+
+// Number extracts the numeric value of a STRING or RAW value containing a number
+// (e.g. "21.5"), or of a JSON value being a number.
+func Number(v yaks.Value) (float64, error) {
+	if v.Encoding() == yaks.JSON {
+		return jsonNumber(v.Encode(), nil)
+	}
+	if v.Encoding() == yaks.PROPERTIES {
+		return 0, errors.New("PROPERTIES value is not a number (use a Field)")
+	}
+	return parseNumber(string(v.Encode()))
+}
+
+// Field returns an Extractor for the numeric field at path (e.g. "sensor.temperature",
+// with '.' separating the keys of nested objects and indexes of arrays) of a JSON value,
+// or for the property with path as key of a PROPERTIES value.
+func Field(path string) Extractor {
+	keys := strings.Split(path, ".")
+	return func(v yaks.Value) (float64, error) {
+		if v.Encoding() == yaks.JSON {
+			return jsonNumber(v.Encode(), keys)
+		}
+		if v.Encoding() != yaks.PROPERTIES {
+			return 0, errors.New("no field " + path + " in a value that is not JSON or PROPERTIES")
+		}
+		for _, kv := range strings.Split(string(v.Encode()), ";") {
+			if i := strings.Index(kv, "="); i >= 0 && kv[:i] == path {
+				return parseNumber(kv[i+1:])
+			}
+		}
+		return 0, errors.New("no property " + path)
+	}
+}
+
+func parseNumber(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, errors.New("not a number: " + strconv.Quote(s))
+	}
+	return f, nil
+}
+
+// jsonNumber returns the number at the field path keys of a JSON document
+func jsonNumber(data []byte, keys []string) (float64, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return 0, err
+	}
+	for i, key := range keys {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if doc, ok = node[key]; !ok {
+				return 0, errors.New("no field " + strings.Join(keys[:i+1], "."))
+			}
+		case []interface{}:
+			n, err := strconv.Atoi(key)
+			if err != nil || n < 0 || n >= len(node) {
+				return 0, errors.New("no index " + strings.Join(keys[:i+1], "."))
+			}
+			doc = node[n]
+		default:
+			return 0, errors.New("no field " + strings.Join(keys[:i+1], "."))
+		}
+	}
+	n, ok := doc.(json.Number)
+	if !ok {
+		return 0, errors.New("JSON field " + strings.Join(keys, ".") + " is not a number")
+	}
+	return n.Float64()
+}
+
+// Stats are the statistics of the numeric values of some entries
+type Stats struct {
+	// Count is the number of numeric values
+	Count int
+	// Skipped is the number of entries without numeric value (for the Extractor)
+	Skipped int
+	// Sum is the sum of the values
+	Sum float64
+	// Min is the minimum value
+	Min float64
+	// Max is the maximum value
+	Max float64
+	// Mean is the mean of the values
+	Mean float64
+	// Last is the value of the last entry with a numeric value
+	Last float64
+	// LastTimestamp is the timestamp of the last entry with a numeric value
+	LastTimestamp *yaks.Timestamp
+}
+
+// Compute computes the Stats of the numeric values extracted from entries,
+// skipping the entries without numeric value. The last value is the one with the latest Timestamp.
+func Compute(entries []yaks.Entry, extract Extractor) Stats {
+	var s Stats
+	for i := range entries {
+		f, err := extract(entries[i].Value())
+		if err != nil {
+			s.Skipped++
+			continue
+		}
+		if s.Count == 0 || f < s.Min {
+			s.Min = f
+		}
+		if s.Count == 0 || f > s.Max {
+			s.Max = f
+		}
+		s.Count++
+		s.Sum += f
+		if ts := entries[i].Timestamp(); s.LastTimestamp == nil || !ts.Before(s.LastTimestamp) {
+			s.Last = f
+			s.LastTimestamp = ts
+		}
+	}
+	if s.Count > 0 {
+		s.Mean = s.Sum / float64(s.Count)
+	}
+	return s
+}
+
+// ComputeByPath computes the Stats of the entries of each path, as Compute does
+func ComputeByPath(entries []yaks.Entry, extract Extractor) map[yaks.Path]Stats {
+	byPath := make(map[yaks.Path][]yaks.Entry)
+	for _, e := range entries {
+		byPath[*e.Path()] = append(byPath[*e.Path()], e)
+	}
+	result := make(map[yaks.Path]Stats, len(byPath))
+	for path, es := range byPath {
+		result[path] = Compute(es, extract)
+	}
+	return result
+}
+
+// of returns an AggregateFunc returning a field of the Stats, or ErrNoValue if there's no numeric value
+func of(extract Extractor, field func(*Stats) float64) yaks.AggregateFunc {
+	return func(entries []yaks.Entry) (float64, error) {
+		s := Compute(entries, extract)
+		if s.Count == 0 {
+			return 0, ErrNoValue
+		}
+		return field(&s), nil
+	}
+}
+
+// Count returns an AggregateFunc counting the numeric values (0 if none)
+func Count(extract Extractor) yaks.AggregateFunc {
+	return func(entries []yaks.Entry) (float64, error) {
+		return float64(Compute(entries, extract).Count), nil
+	}
+}
+
+// Sum returns an AggregateFunc summing the numeric values
+func Sum(extract Extractor) yaks.AggregateFunc {
+	return of(extract, func(s *Stats) float64 { return s.Sum })
+}
+
+// Min returns an AggregateFunc returning the minimum numeric value
+func Min(extract Extractor) yaks.AggregateFunc {
+	return of(extract, func(s *Stats) float64 { return s.Min })
+}
+
+// Max returns an AggregateFunc returning the maximum numeric value
+func Max(extract Extractor) yaks.AggregateFunc {
+	return of(extract, func(s *Stats) float64 { return s.Max })
+}
+
+// Mean returns an AggregateFunc returning the mean of the numeric values
+func Mean(extract Extractor) yaks.AggregateFunc {
+	return of(extract, func(s *Stats) float64 { return s.Mean })
+}
+
+// Last returns an AggregateFunc returning the numeric value with the latest Timestamp
+func Last(extract Extractor) yaks.AggregateFunc {
+	return of(extract, func(s *Stats) float64 { return s.Last })
+}
@@ -0,0 +1,166 @@
+package aggregate_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/aggregate"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+// entries puts the values on the paths /s/a, /s/b... in this order, and returns the resulting entries
+func entries(t *testing.T, values ...yaks.Value) []yaks.Entry {
+	t.Helper()
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/s/**", nil)
+	w := s.Workspace("/s")
+	for i, v := range values {
+		p, _ := yaks.NewPath(string(rune('a' + i)))
+		if err := w.Put(p, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sel, _ := yaks.NewSelector("/s/*")
+	result, err := w.GetWithOptions(sel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != len(values) {
+		t.Fatalf("got %d entries, want %d", len(result), len(values))
+	}
+	return result
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		value   yaks.Value
+		want    float64
+		wantErr bool
+	}{
+		{yaks.NewStringValue("21.5"), 21.5, false},
+		{yaks.NewStringValue(" -3 "), -3, false},
+		{yaks.NewRawValue([]byte("1e3")), 1000, false},
+		{yaks.NewJSONValue("42"), 42, false},
+		{yaks.NewStringValue("abc"), 0, true},
+		{yaks.NewStringValue(""), 0, true},
+		{yaks.NewJSONValue(`{"a":1}`), 0, true},
+		{yaks.NewJSONValue(`"12"`), 0, true},
+		{yaks.NewPropertiesValue(yaks.Properties{"a": "1"}), 0, true},
+	}
+	for _, tt := range tests {
+		got, err := aggregate.Number(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Number(%q) = %v, %v; want %v (error: %v)", tt.value.ToString(), got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestField(t *testing.T) {
+	doc := yaks.NewJSONValue(`{"sensor":{"temperature":21.5,"name":"s1","values":[1,2.5,{"x":3}]},"n":null}`)
+	tests := []struct {
+		path    string
+		value   yaks.Value
+		want    float64
+		wantErr bool
+	}{
+		{"sensor.temperature", doc, 21.5, false},
+		{"sensor.values.1", doc, 2.5, false},
+		{"sensor.values.2.x", doc, 3, false},
+		{"sensor", doc, 0, true},
+		{"sensor.name", doc, 0, true},
+		{"sensor.missing", doc, 0, true},
+		{"sensor.values.3", doc, 0, true},
+		{"sensor.values.-1", doc, 0, true},
+		{"sensor.values.x", doc, 0, true},
+		{"sensor.temperature.x", doc, 0, true},
+		{"n", doc, 0, true},
+		{"t", yaks.NewJSONValue("{"), 0, true},
+		{"t", yaks.NewPropertiesValue(yaks.Properties{"t": "12", "u": "x"}), 12, false},
+		{"u", yaks.NewPropertiesValue(yaks.Properties{"t": "12", "u": "x"}), 0, true},
+		{"v", yaks.NewPropertiesValue(yaks.Properties{"t": "12"}), 0, true},
+		{"t", yaks.NewStringValue("12"), 0, true},
+	}
+	for _, tt := range tests {
+		got, err := aggregate.Field(tt.path)(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Field(%q)(%s) = %v, %v; want %v (error: %v)", tt.path, tt.value.ToString(), got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []yaks.Value
+		want   aggregate.Stats
+	}{
+		{"empty", nil, aggregate.Stats{}},
+		{"numbers", []yaks.Value{yaks.NewStringValue("3"), yaks.NewStringValue("-1"), yaks.NewStringValue("4")},
+			aggregate.Stats{Count: 3, Sum: 6, Min: -1, Max: 4, Mean: 2, Last: 4}},
+		{"non-numeric skipped", []yaks.Value{yaks.NewStringValue("2"), yaks.NewStringValue("x"), yaks.NewStringValue("4")},
+			aggregate.Stats{Count: 2, Skipped: 1, Sum: 6, Min: 2, Max: 4, Mean: 3, Last: 4}},
+		{"last non-numeric", []yaks.Value{yaks.NewStringValue("2"), yaks.NewStringValue("x")},
+			aggregate.Stats{Count: 1, Skipped: 1, Sum: 2, Min: 2, Max: 2, Mean: 2, Last: 2}},
+		{"only non-numeric", []yaks.Value{yaks.NewStringValue("x"), yaks.NewRawValue([]byte{0xff})},
+			aggregate.Stats{Skipped: 2}},
+	}
+	for _, tt := range tests {
+		var es []yaks.Entry
+		if len(tt.values) > 0 {
+			es = entries(t, tt.values...)
+		}
+		got := aggregate.Compute(es, aggregate.Number)
+		if got.Count > 0 && got.LastTimestamp == nil {
+			t.Errorf("%s: no LastTimestamp", tt.name)
+		}
+		got.LastTimestamp = nil
+		if got != tt.want {
+			t.Errorf("%s: Compute = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAggregateFuncs(t *testing.T) {
+	es := entries(t, yaks.NewStringValue("1"), yaks.NewStringValue("x"), yaks.NewStringValue("5"))
+	funcs := []struct {
+		name string
+		fn   yaks.AggregateFunc
+		want float64
+	}{
+		{"Count", aggregate.Count(aggregate.Number), 2},
+		{"Sum", aggregate.Sum(aggregate.Number), 6},
+		{"Min", aggregate.Min(aggregate.Number), 1},
+		{"Max", aggregate.Max(aggregate.Number), 5},
+		{"Mean", aggregate.Mean(aggregate.Number), 3},
+		{"Last", aggregate.Last(aggregate.Number), 5},
+	}
+	for _, f := range funcs {
+		if got, err := f.fn(es); err != nil || math.Abs(got-f.want) > 1e-9 {
+			t.Errorf("%s = %v, %v; want %v", f.name, got, err, f.want)
+		}
+		got, err := f.fn(nil)
+		if f.name == "Count" {
+			if err != nil || got != 0 {
+				t.Errorf("Count of no entries = %v, %v; want 0", got, err)
+			}
+		} else if err != aggregate.ErrNoValue {
+			t.Errorf("%s of no entries: error %v, want ErrNoValue", f.name, err)
+		}
+	}
+}
+
+func TestComputeByPath(t *testing.T) {
+	es := entries(t, yaks.NewStringValue("1"), yaks.NewStringValue("2"))
+	stats := aggregate.ComputeByPath(es, aggregate.Number)
+	if len(stats) != 2 {
+		t.Fatalf("ComputeByPath returned %d paths, want 2", len(stats))
+	}
+	for _, e := range es {
+		want, _ := aggregate.Number(e.Value())
+		if s := stats[*e.Path()]; s.Count != 1 || s.Sum != want {
+			t.Errorf("stats of %s = %+v, want the value %v", e.Path(), s, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package yaks
+
+import (
+	"context"
+	"time"
+)
+
+// AggregateFunc computes an aggregated value from entries of a same path, sorted per Timestamp
+// (see the aggregate package for the usual functions).
+type AggregateFunc func(entries []Entry) (float64, error)
+
+// Bucket is the aggregated value of the entries of a path within a time interval
+type Bucket struct {
+	// Start is the start of the time interval (inclusive)
+	Start time.Time
+	// End is the end of the time interval (exclusive)
+	End time.Time
+	// Entries is the number of entries in the time interval
+	Entries int
+	// Value is the aggregated value
+	Value float64
+	// Err is the error returned by the AggregateFunc (if any, Value is not set)
+	Err error
+}
+
+// Aggregate gets the time series of the path/values matching selector, as GetSeries does,
+// and aggregates the entries of each path with fn, per time interval of duration bucket
+// (aligned on multiples of bucket since the zero time). If bucket is 0, all the entries
+// of each path are aggregated in a single Bucket. The Buckets of each path are sorted by Start,
+// and only the time intervals with entries have a Bucket.
+func (w *Workspace) Aggregate(selector *Selector, fn AggregateFunc, bucket time.Duration) (map[Path][]Bucket, error) {
+	return w.AggregateContext(context.Background(), selector, fn, bucket)
+}
+
+// AggregateContext is the same as Aggregate, with a context as for GetContext.
+func (w *Workspace) AggregateContext(ctx context.Context, selector *Selector, fn AggregateFunc, bucket time.Duration) (map[Path][]Bucket, error) {
+	series, err := w.GetSeriesContext(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[Path][]Bucket, len(series))
+	for path, entries := range series {
+		result[path] = aggregateBuckets(entries, fn, bucket)
+	}
+	return result, nil
+}
+
+// aggregateBuckets aggregates the entries (sorted per Timestamp) per time interval
+func aggregateBuckets(entries []Entry, fn AggregateFunc, bucket time.Duration) []Bucket {
+	if len(entries) == 0 {
+		return nil
+	}
+	var buckets []Bucket
+	aggregate := func(start time.Time, end time.Time, entries []Entry) {
+		b := Bucket{Start: start, End: end, Entries: len(entries)}
+		b.Value, b.Err = fn(entries)
+		buckets = append(buckets, b)
+	}
+	if bucket <= 0 {
+		aggregate(entries[0].tstamp.GoTime(), entries[len(entries)-1].tstamp.GoTime().Add(1), entries)
+		return buckets
+	}
+	first := 0
+	start := entries[0].tstamp.GoTime().Truncate(bucket)
+	for i := range entries {
+		if s := entries[i].tstamp.GoTime().Truncate(bucket); !s.Equal(start) {
+			aggregate(start, start.Add(bucket), entries[first:i])
+			first, start = i, s
+		}
+	}
+	aggregate(start, start.Add(bucket), entries[first:])
+	return buckets
+}
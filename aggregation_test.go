@@ -0,0 +1,82 @@
+package yaks
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// seriesAt returns the entries of a path with the values at the given times (in seconds)
+func seriesAt(values map[int64]Value, times ...int64) []Entry {
+	path, _ := NewPath("/a")
+	es := make([]Entry, len(times))
+	for i, sec := range times {
+		ts := NewTimestamp(time.Unix(sec, 0), [16]byte{})
+		es[i] = Entry{path: path, value: values[sec], tstamp: &ts}
+	}
+	return es
+}
+
+// sumStrings sums the values as numbers, failing on the first non-numeric value
+func sumStrings(entries []Entry) (float64, error) {
+	sum := 0.0
+	for _, e := range entries {
+		f, err := strconv.ParseFloat(e.Value().ToString(), 64)
+		if err != nil {
+			return 0, errors.New("not a number")
+		}
+		sum += f
+	}
+	return sum, nil
+}
+
+func TestAggregateBuckets(t *testing.T) {
+	values := map[int64]Value{
+		10: NewStringValue("1"),
+		15: NewStringValue("2"),
+		25: NewStringValue("x"),
+		42: NewStringValue("4"),
+		59: NewStringValue("5"),
+	}
+	type bucket struct {
+		start, end int64
+		entries    int
+		value      float64
+		err        bool
+	}
+	tests := []struct {
+		name   string
+		times  []int64
+		bucket time.Duration
+		want   []bucket
+	}{
+		{"empty", nil, 10 * time.Second, nil},
+		{"empty single bucket", nil, 0, nil},
+		{"single bucket", []int64{10, 15, 42}, 0, []bucket{{10, 42, 3, 7, false}}},
+		{"aligned buckets", []int64{10, 15, 42, 59}, 20 * time.Second,
+			[]bucket{{0, 20, 2, 3, false}, {40, 60, 2, 9, false}}},
+		{"non-numeric value", []int64{10, 25, 42}, 10 * time.Second,
+			[]bucket{{10, 20, 1, 1, false}, {20, 30, 1, 0, true}, {40, 50, 1, 4, false}}},
+		{"one entry", []int64{59}, time.Minute, []bucket{{0, 60, 1, 5, false}}},
+	}
+	for _, tt := range tests {
+		got := aggregateBuckets(seriesAt(values, tt.times...), sumStrings, tt.bucket)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d buckets, want %d: %+v", tt.name, len(got), len(tt.want), got)
+			continue
+		}
+		for i, b := range got {
+			w := tt.want[i]
+			end := time.Unix(w.end, 0)
+			if tt.bucket == 0 {
+				// the single bucket ends just after the last entry
+				end = end.Add(1)
+			}
+			if !b.Start.Equal(time.Unix(w.start, 0)) || !b.End.Equal(end) || b.Entries != w.entries ||
+				(b.Err != nil) != w.err || (!w.err && b.Value != w.value) {
+				t.Errorf("%s: bucket %d = %+v, want %+v", tt.name, i, b, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package yaks
+
+import "context"
+
+// DefaultMaxInFlight is the default maximum number of asynchronous writes in flight per Workspace.
+const DefaultMaxInFlight = 256
+
+// WriteResult is the future result of an asynchronous write.
+type WriteResult struct {
+	done chan struct{}
+	err  error
+}
+
+// Done returns a channel that is closed when the write has been handed to the transport (or has failed).
+func (r *WriteResult) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err waits for the write to be handed to the transport and returns its error (nil if it succeeded).
+func (r *WriteResult) Err() error {
+	<-r.done
+	return r.err
+}
+
+// writeAsync runs write in its own subroutine, after the completion of the previous asynchronous
+// write of the Workspace (preserving their order). It blocks while the maximum number of
+// asynchronous writes in flight is reached.
+func (w *Workspace) writeAsync(write func(ctx context.Context) error) *WriteResult {
+	r := &WriteResult{done: make(chan struct{})}
+	w.inflight <- struct{}{}
+	w.mu.Lock()
+	prev := w.lastAsync
+	w.lastAsync = r
+	w.mu.Unlock()
+	go func() {
+		if prev != nil {
+			<-prev.done
+		}
+		r.err = write(context.Background())
+		<-w.inflight
+		close(r.done)
+	}()
+	return r
+}
+
+// PutAsync puts a path/value into Yaks, without waiting for the put to be handed to the transport.
+// The asynchronous writes of a Workspace are sent in order. If the maximum number of asynchronous
+// writes in flight is reached (see Config.MaxInFlight), PutAsync blocks until one completes.
+func (w *Workspace) PutAsync(path *Path, value Value) *WriteResult {
+	return w.writeAsync(func(ctx context.Context) error {
+		return w.PutContext(ctx, path, value)
+	})
+}
+
+// UpdateAsync updates a path/value into Yaks, without waiting for the update to be handed to the transport.
+// It behaves as PutAsync regarding the ordering and the maximum number of writes in flight.
+func (w *Workspace) UpdateAsync(path *Path, value Value) *WriteResult {
+	return w.writeAsync(func(ctx context.Context) error {
+		return w.UpdateContext(ctx, path, value)
+	})
+}
+
+// RemoveAsync removes a path/value from Yaks, without waiting for the remove to be handed to the transport.
+// It behaves as PutAsync regarding the ordering and the maximum number of writes in flight.
+func (w *Workspace) RemoveAsync(path *Path) *WriteResult {
+	return w.writeAsync(func(ctx context.Context) error {
+		return w.RemoveContext(ctx, path)
+	})
+}
@@ -0,0 +1,142 @@
+package yaks_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/memory"
+)
+
+// writeRecorder is a session with a memory.Engine recording its writes, which are blocked while gate is held
+type writeRecorder struct {
+	yaks.Transport
+	gate   sync.RWMutex
+	mu     sync.Mutex
+	writes []string
+}
+
+func (t *writeRecorder) WriteDataWO(path string, payload []byte, encoding yaks.Encoding, kind yaks.ChangeKind) error {
+	t.gate.RLock()
+	defer t.gate.RUnlock()
+	t.mu.Lock()
+	t.writes = append(t.writes, fmt.Sprintf("%d %s", kind, path))
+	t.mu.Unlock()
+	return t.Transport.WriteDataWO(path, payload, encoding, kind)
+}
+
+// MergesUpdates returns true, for the updates to be written as is
+func (t *writeRecorder) MergesUpdates() bool {
+	return true
+}
+
+func (t *writeRecorder) written() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.writes...)
+}
+
+func loginWithRecorder(t *testing.T, maxInFlight int) (*yaks.Yaks, *writeRecorder) {
+	t.Helper()
+	tr := &writeRecorder{Transport: memory.NewEngine().NewTransport()}
+	c := &yaks.Config{MaxInFlight: maxInFlight}
+	y, err := c.Login(func(*string, yaks.Properties) (yaks.Transport, error) { return tr, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return y, tr
+}
+
+func TestAsyncWritesOrder(t *testing.T) {
+	y, tr := loginWithRecorder(t, 0)
+	defer y.Logout()
+	w := y.Workspace(path(t, "/"))
+	var want []string
+	var results []*yaks.WriteResult
+	for i := 0; i < 20; i++ {
+		p := fmt.Sprintf("/a/%d", i)
+		switch i % 3 {
+		case 0:
+			results = append(results, w.PutAsync(path(t, p), yaks.NewStringValue("1")))
+			want = append(want, fmt.Sprintf("%d %s", yaks.PUT, p))
+		case 1:
+			results = append(results, w.UpdateAsync(path(t, p), yaks.NewStringValue("2")))
+			want = append(want, fmt.Sprintf("%d %s", yaks.UPDATE, p))
+		case 2:
+			results = append(results, w.RemoveAsync(path(t, p)))
+			want = append(want, fmt.Sprintf("%d %s", yaks.REMOVE, p))
+		}
+	}
+	for _, r := range results {
+		if err := r.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := tr.written(); !equalStrings(got, want) {
+		t.Errorf("writes = %v, want %v", got, want)
+	}
+}
+
+func TestAsyncWritesInFlightLimit(t *testing.T) {
+	y, tr := loginWithRecorder(t, 2)
+	defer y.Logout()
+	w := y.Workspace(path(t, "/"))
+
+	tr.gate.Lock()
+	first := w.PutAsync(path(t, "/a/1"), yaks.NewStringValue("1"))
+	w.PutAsync(path(t, "/a/2"), yaks.NewStringValue("2"))
+	queued := make(chan *yaks.WriteResult)
+	go func() {
+		queued <- w.PutAsync(path(t, "/a/3"), yaks.NewStringValue("3"))
+	}()
+	select {
+	case <-queued:
+		t.Fatal("PutAsync returned with 2 writes in flight")
+	case <-first.Done():
+		t.Fatal("write completed while the transport is blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tr.gate.Unlock()
+	var third *yaks.WriteResult
+	select {
+	case third = <-queued:
+	case <-time.After(5 * time.Second):
+		t.Fatal("PutAsync still blocked once the writes completed")
+	}
+	if err := third.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tr.written(); len(got) != 3 {
+		t.Errorf("writes = %v, want the 3 puts", got)
+	}
+}
+
+func TestAsyncWritesOnClosedWorkspace(t *testing.T) {
+	y, tr := loginWithRecorder(t, 0)
+	defer y.Logout()
+	w := y.Workspace(path(t, "/"))
+	w.Close()
+
+	for op, r := range map[string]*yaks.WriteResult{
+		"Put":    w.PutAsync(path(t, "/a/x"), yaks.NewStringValue("1")),
+		"Update": w.UpdateAsync(path(t, "/a/x"), yaks.NewStringValue("1")),
+		"Remove": w.RemoveAsync(path(t, "/a/x")),
+	} {
+		select {
+		case <-r.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%sAsync on a closed Workspace not done", op)
+		}
+		var yerr *yaks.YError
+		if err := r.Err(); !errors.Is(err, yaks.ErrClosed) || !errors.As(err, &yerr) || yerr.Op != op || yerr.Path != "/a/x" {
+			t.Errorf("%sAsync on a closed Workspace: %v, want a YError on /a/x matching ErrClosed", op, err)
+		}
+	}
+	if got := tr.written(); len(got) != 0 {
+		t.Errorf("writes = %v, want none", got)
+	}
+}
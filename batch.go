@@ -0,0 +1,107 @@
+package yaks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Batch collects Put, Update and Remove operations to be sent at once with Workspace.PutBatch.
+// The subscribers receive all the changes of a batch in a single Listener call.
+// Notice that not all the Transports support batches (the Zenoh Transport doesn't).
+type Batch struct {
+	ops []batchOp
+}
+
+// batchOp is an operation collected in a Batch
+type batchOp struct {
+	path  *Path
+	value Value
+	kind  ChangeKind
+}
+
+// NewBatch returns a new empty Batch.
+func NewBatch() *Batch {
+	return new(Batch)
+}
+
+// Put adds a put of a path/value to the Batch.
+func (b *Batch) Put(path *Path, value Value) *Batch {
+	b.ops = append(b.ops, batchOp{path, value, PUT})
+	return b
+}
+
+// Update adds an update of a path/value to the Batch.
+// Notice that, unlike Workspace.Update, the update is sent as is even if the Transport doesn't merge the updates.
+func (b *Batch) Update(path *Path, value Value) *Batch {
+	b.ops = append(b.ops, batchOp{path, value, UPDATE})
+	return b
+}
+
+// Remove adds a remove of a path/value to the Batch.
+func (b *Batch) Remove(path *Path) *Batch {
+	b.ops = append(b.ops, batchOp{path, nil, REMOVE})
+	return b
+}
+
+// Len returns the number of operations in the Batch.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+func newBatchID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// PutBatch sends all the operations of the Batch at once, with the same timestamp if the Transport allows it
+// (the zenohtransport package sends them one after the other, each with its own timestamp).
+// It returns the batch id, which is the BatchID of the resulting Changes.
+func (w *Workspace) PutBatch(batch *Batch) (string, error) {
+	return w.PutBatchContext(context.Background(), batch)
+}
+
+// PutBatchContext sends all the operations of the Batch at once, with the same timestamp if the Transport allows it.
+// It returns the batch id, which is the BatchID of the resulting Changes.
+// If the Transport doesn't support batches, nothing is sent and an error matching ErrUnsupported is returned.
+// If ctx is done before the batch is sent, it returns an error matching ctx.Err()
+// (and ErrTimeout if the deadline of ctx is exceeded) and nothing is sent.
+// Once sent, the batch is not bound to ctx anymore.
+func (w *Workspace) PutBatchContext(ctx context.Context, batch *Batch) (string, error) {
+	batchID := newBatchID()
+	logger.WithFields(log.Fields{
+		"batch": batchID,
+		"len":   batch.Len(),
+	}).Debug("PutBatch")
+	if batch.Len() == 0 {
+		return batchID, nil
+	}
+	samples := make([]Sample, len(batch.ops))
+	for i, op := range batch.ops {
+		samples[i].Path = w.toAbsolutePath(op.path).ToString()
+		samples[i].Kind = op.kind
+		if op.value != nil {
+			samples[i].Data = op.value.Encode()
+			samples[i].Encoding = op.value.Encoding()
+		}
+	}
+	if ctx.Err() != nil {
+		return "", contextError(ctx, "PutBatch", w.path.ToString())
+	}
+	t, err := w.connectedSession("PutBatch", w.path.ToString())
+	if err != nil {
+		return "", err
+	}
+	if e := t.WriteBatch(batchID, samples); e != nil {
+		if errors.Is(e, ErrUnsupported) {
+			return "", &YError{Op: "PutBatch", Path: w.path.ToString(), kind: ErrUnsupported, cause: e}
+		}
+		w.yaks.transportFailed(t, e)
+		return "", &YError{Op: "PutBatch", Path: w.path.ToString(), cause: e}
+	}
+	return batchID, nil
+}
@@ -0,0 +1,36 @@
+package yaks_test
+
+import (
+	"testing"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+func TestPutBatch(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	w := s.Workspace("/")
+	c := newChanges()
+	if _, err := w.Subscribe(selector(t, "/a/*"), c.listener); err != nil {
+		t.Fatal(err)
+	}
+	w.Put(path(t, "/a/x"), yaks.NewStringValue("1"))
+	batch := yaks.NewBatch().
+		Put(path(t, "/a/y"), yaks.NewStringValue("2")).
+		Put(path(t, "/b/y"), yaks.NewStringValue("3")).
+		Remove(path(t, "/a/x"))
+	batchID, err := w.PutBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := c.await(t, 2)
+	// only the changes of the batch matching the selector, notified together
+	if len(received[1]) != 2 || received[1][0].BatchID() != batchID || received[1][0].Path().ToString() != "/a/y" ||
+		received[1][1].Kind() != yaks.REMOVE {
+		t.Errorf("batch notification = %+v", received[1])
+	}
+	if id, err := w.PutBatch(yaks.NewBatch()); err != nil || id == "" {
+		t.Errorf("empty PutBatch = %q, %v", id, err)
+	}
+}
@@ -0,0 +1,361 @@
+package yaks
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the configuration of a session with Yaks, to be used with Config.Login.
+// It can be built with functional Options, loaded from a YAML or JSON file and from
+// the YAKS_* environment variables.
+type Config struct {
+	// Locators is the ordered list of the Zenoh locators to try (dynamic discovery if empty).
+	Locators []string
+	// User is the user name used for authentication.
+	User string
+	// Password is the password used for authentication.
+	Password string
+	// AttemptTimeout is the maximum duration of a connection attempt on a locator (no limit if 0).
+	AttemptTimeout time.Duration
+	// Retry is the RetryPolicy to apply at login and when the session is lost.
+	Retry RetryPolicy
+	// Executor makes Yaks.Workspace() create Workspaces that behave as with Yaks.WorkspaceWithExecutor().
+	Executor bool
+	// KeepAlive is the period of the liveness checks of the session (DefaultKeepAlive if 0, no check if negative).
+	KeepAlive time.Duration
+	// MaxInFlight is the maximum number of asynchronous writes in flight per Workspace (DefaultMaxInFlight if 0).
+	MaxInFlight int
+	// LogLevel is the logrus level to be set (e.g. "info", "debug"...). The level is unchanged if empty.
+	LogLevel string
+}
+
+// Option is a functional option configuring a Config.
+type Option func(*Config) error
+
+// NewConfig returns a new Config, starting from the defaults and applying all the options in order.
+func NewConfig(options ...Option) (*Config, error) {
+	c := &Config{Retry: DefaultRetryPolicy}
+	for _, opt := range options {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WithLocators sets the ordered list of Zenoh locators to try.
+func WithLocators(locators ...string) Option {
+	return func(c *Config) error {
+		c.Locators = locators
+		return nil
+	}
+}
+
+// WithCredentials sets the user and password used for authentication.
+func WithCredentials(user string, password string) Option {
+	return func(c *Config) error {
+		c.User = user
+		c.Password = password
+		return nil
+	}
+}
+
+// WithAttemptTimeout sets the maximum duration of a connection attempt on a locator.
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		c.AttemptTimeout = timeout
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the RetryPolicy to apply at login and when the session is lost.
+func WithRetryPolicy(retry RetryPolicy) Option {
+	return func(c *Config) error {
+		c.Retry = retry
+		return nil
+	}
+}
+
+// WithKeepAlive sets the period of the liveness checks of the session (no check if negative).
+func WithKeepAlive(period time.Duration) Option {
+	return func(c *Config) error {
+		c.KeepAlive = period
+		return nil
+	}
+}
+
+// WithExecutor sets if Yaks.Workspace() creates Workspaces with their own executor.
+func WithExecutor(executor bool) Option {
+	return func(c *Config) error {
+		c.Executor = executor
+		return nil
+	}
+}
+
+// WithMaxInFlight sets the maximum number of asynchronous writes in flight per Workspace.
+func WithMaxInFlight(n int) Option {
+	return func(c *Config) error {
+		if n < 0 {
+			return &YError{msg: "invalid max in flight: " + strconv.Itoa(n)}
+		}
+		c.MaxInFlight = n
+		return nil
+	}
+}
+
+// WithLogLevel sets the logrus level to be set at login.
+func WithLogLevel(level string) Option {
+	return func(c *Config) error {
+		if _, err := log.ParseLevel(level); err != nil {
+			return &YError{msg: "invalid log level: " + level, cause: err}
+		}
+		c.LogLevel = level
+		return nil
+	}
+}
+
+// WithFile loads the configuration from a YAML (".yaml" or ".yml" extension) or JSON (".json" extension) file.
+// Only the settings present in the file are changed. Unknown keys are rejected.
+func WithFile(filename string) Option {
+	return func(c *Config) error {
+		return c.LoadFile(filename)
+	}
+}
+
+// WithEnv loads the configuration from the YAKS_* environment variables.
+// Only the settings of the defined variables are changed. Unknown YAKS_* variables are rejected.
+func WithEnv() Option {
+	return func(c *Config) error {
+		return c.LoadEnv()
+	}
+}
+
+// fileConfig is the representation of a Config in a file
+type fileConfig struct {
+	Locators       []string `json:"locators" yaml:"locators"`
+	User           *string  `json:"user" yaml:"user"`
+	Password       *string  `json:"password" yaml:"password"`
+	AttemptTimeout *string  `json:"attempt_timeout" yaml:"attempt_timeout"`
+	Retry          *struct {
+		MaxRounds *int    `json:"max_rounds" yaml:"max_rounds"`
+		MinDelay  *string `json:"min_delay" yaml:"min_delay"`
+		MaxDelay  *string `json:"max_delay" yaml:"max_delay"`
+	} `json:"retry" yaml:"retry"`
+	KeepAlive   *string `json:"keep_alive" yaml:"keep_alive"`
+	Executor    *bool   `json:"executor" yaml:"executor"`
+	MaxInFlight *int    `json:"max_in_flight" yaml:"max_in_flight"`
+	LogLevel    *string `json:"log_level" yaml:"log_level"`
+}
+
+// LoadFile loads the configuration from a YAML (".yaml" or ".yml" extension) or JSON (".json" extension) file.
+// Only the settings present in the file are changed. Unknown keys are rejected.
+func (c *Config) LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return &YError{msg: "failed to read config file " + filename, cause: err}
+	}
+	var fc fileConfig
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &fc)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&fc)
+	default:
+		return &YError{msg: "unsupported config file format: " + filename + " (expecting .yaml, .yml or .json)"}
+	}
+	if err != nil {
+		return &YError{msg: "invalid config file " + filename, cause: err}
+	}
+
+	if fc.Locators != nil {
+		c.Locators = fc.Locators
+	}
+	if fc.User != nil {
+		c.User = *fc.User
+	}
+	if fc.Password != nil {
+		c.Password = *fc.Password
+	}
+	if err := setDuration(&c.AttemptTimeout, "attempt_timeout", fc.AttemptTimeout); err != nil {
+		return err
+	}
+	if fc.Retry != nil {
+		if fc.Retry.MaxRounds != nil {
+			c.Retry.MaxRounds = *fc.Retry.MaxRounds
+		}
+		if err := setDuration(&c.Retry.MinDelay, "retry.min_delay", fc.Retry.MinDelay); err != nil {
+			return err
+		}
+		if err := setDuration(&c.Retry.MaxDelay, "retry.max_delay", fc.Retry.MaxDelay); err != nil {
+			return err
+		}
+	}
+	if err := setDuration(&c.KeepAlive, "keep_alive", fc.KeepAlive); err != nil {
+		return err
+	}
+	if fc.Executor != nil {
+		c.Executor = *fc.Executor
+	}
+	if fc.MaxInFlight != nil {
+		if err := WithMaxInFlight(*fc.MaxInFlight)(c); err != nil {
+			return err
+		}
+	}
+	if fc.LogLevel != nil {
+		return WithLogLevel(*fc.LogLevel)(c)
+	}
+	return nil
+}
+
+func setDuration(d *time.Duration, key string, s *string) error {
+	if s == nil {
+		return nil
+	}
+	v, err := time.ParseDuration(*s)
+	if err != nil {
+		return &YError{msg: "invalid duration for " + key + ": " + *s, cause: err}
+	}
+	*d = v
+	return nil
+}
+
+// Environment variables prefix
+const envPrefix = "YAKS_"
+
+// envSetters are the functions applying each of the YAKS_* environment variables to a Config
+var envSetters = map[string]func(c *Config, v string) error{
+	"YAKS_LOCATORS": func(c *Config, v string) error {
+		c.Locators = nil
+		for _, l := range strings.Split(v, ",") {
+			if l = strings.TrimSpace(l); len(l) > 0 {
+				c.Locators = append(c.Locators, l)
+			}
+		}
+		return nil
+	},
+	"YAKS_USER": func(c *Config, v string) error {
+		c.User = v
+		return nil
+	},
+	"YAKS_PASSWORD": func(c *Config, v string) error {
+		c.Password = v
+		return nil
+	},
+	"YAKS_ATTEMPT_TIMEOUT": func(c *Config, v string) error {
+		return setDuration(&c.AttemptTimeout, "YAKS_ATTEMPT_TIMEOUT", &v)
+	},
+	"YAKS_RETRY_MAX_ROUNDS": func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return &YError{msg: "invalid integer for YAKS_RETRY_MAX_ROUNDS: " + v, cause: err}
+		}
+		c.Retry.MaxRounds = i
+		return nil
+	},
+	"YAKS_RETRY_MIN_DELAY": func(c *Config, v string) error {
+		return setDuration(&c.Retry.MinDelay, "YAKS_RETRY_MIN_DELAY", &v)
+	},
+	"YAKS_RETRY_MAX_DELAY": func(c *Config, v string) error {
+		return setDuration(&c.Retry.MaxDelay, "YAKS_RETRY_MAX_DELAY", &v)
+	},
+	"YAKS_KEEP_ALIVE": func(c *Config, v string) error {
+		return setDuration(&c.KeepAlive, "YAKS_KEEP_ALIVE", &v)
+	},
+	"YAKS_EXECUTOR": func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return &YError{msg: "invalid boolean for YAKS_EXECUTOR: " + v, cause: err}
+		}
+		c.Executor = b
+		return nil
+	},
+	"YAKS_MAX_IN_FLIGHT": func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return &YError{msg: "invalid integer for YAKS_MAX_IN_FLIGHT: " + v, cause: err}
+		}
+		return WithMaxInFlight(i)(c)
+	},
+	"YAKS_LOG_LEVEL": func(c *Config, v string) error {
+		return WithLogLevel(v)(c)
+	},
+}
+
+// LoadEnv loads the configuration from the YAKS_* environment variables:
+// YAKS_LOCATORS (comma-separated list), YAKS_USER, YAKS_PASSWORD, YAKS_ATTEMPT_TIMEOUT,
+// YAKS_RETRY_MAX_ROUNDS, YAKS_RETRY_MIN_DELAY, YAKS_RETRY_MAX_DELAY, YAKS_KEEP_ALIVE, YAKS_EXECUTOR,
+// YAKS_MAX_IN_FLIGHT and YAKS_LOG_LEVEL.
+// Durations use the time.ParseDuration format (e.g. "1.5s").
+// Only the settings of the defined variables are changed. Unknown YAKS_* variables are rejected.
+func (c *Config) LoadEnv() error {
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envPrefix) {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		key, value := kv[:i], kv[i+1:]
+		setter, ok := envSetters[key]
+		if !ok {
+			return &YError{msg: "unknown environment variable: " + key}
+		}
+		if err := setter(c, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) loginOptions() *LoginOptions {
+	props := make(Properties)
+	if len(c.User) > 0 {
+		props[PropUser] = c.User
+	}
+	if len(c.Password) > 0 {
+		props[PropPassword] = c.Password
+	}
+	return &LoginOptions{
+		Locators:       c.Locators,
+		Properties:     props,
+		AttemptTimeout: c.AttemptTimeout,
+		Retry:          c.Retry,
+		KeepAlive:      c.KeepAlive,
+	}
+}
+
+// Login establishes a session with Yaks via dial (or via the default Dialer if dial is nil, see Login), using the Config.
+// Notice that if a LogLevel is configured, it's set on the logrus standard logger.
+func (c *Config) Login(dial Dialer) (*Yaks, error) {
+	if len(c.LogLevel) > 0 {
+		level, err := log.ParseLevel(c.LogLevel)
+		if err != nil {
+			return nil, &YError{msg: "invalid log level: " + c.LogLevel, cause: err}
+		}
+		log.SetLevel(level)
+	}
+	if dial == nil {
+		var err error
+		if dial, err = getDefaultDialer(); err != nil {
+			return nil, err
+		}
+	}
+	y, err := LoginWithDialer(dial, c.loginOptions())
+	if err != nil {
+		return nil, err
+	}
+	y.executor = c.Executor
+	if c.MaxInFlight > 0 {
+		y.maxInFlight = c.MaxInFlight
+	}
+	return y, nil
+}
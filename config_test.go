@@ -0,0 +1,177 @@
+package yaks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a config file named name with content in a temporary directory
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "yaks-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestConfigLoadFile(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", `
+locators: [tcp/1.2.3.4:7447, tcp/5.6.7.8:7447]
+user: u
+password: p
+attempt_timeout: 2s
+retry:
+  max_rounds: 3
+  min_delay: 100ms
+keep_alive: 1m
+executor: true
+max_in_flight: 8
+log_level: debug
+`)
+	jsonFile := writeConfigFile(t, "config.json", `{"user": "u", "retry": {"max_delay": "5s"}}`)
+	defer os.RemoveAll(filepath.Dir(yamlFile))
+	defer os.RemoveAll(filepath.Dir(jsonFile))
+
+	c, err := NewConfig(WithFile(yamlFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		Locators:       []string{"tcp/1.2.3.4:7447", "tcp/5.6.7.8:7447"},
+		User:           "u",
+		Password:       "p",
+		AttemptTimeout: 2 * time.Second,
+		Retry:          RetryPolicy{MaxRounds: 3, MinDelay: 100 * time.Millisecond, MaxDelay: DefaultRetryPolicy.MaxDelay},
+		Executor:       true,
+		KeepAlive:      time.Minute,
+		MaxInFlight:    8,
+		LogLevel:       "debug",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("YAML config = %+v, want %+v", c, want)
+	}
+
+	// only the settings present in the file are changed
+	c, err = NewConfig(WithLocators("tcp/9.9.9.9:7447"), WithFile(jsonFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.User != "u" || c.Retry.MaxDelay != 5*time.Second || c.Retry.MinDelay != DefaultRetryPolicy.MinDelay ||
+		len(c.Locators) != 1 || c.Locators[0] != "tcp/9.9.9.9:7447" {
+		t.Errorf("JSON config = %+v", c)
+	}
+}
+
+func TestConfigLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"config.txt", "user: u", "unsupported config file format"},
+		{"config.yaml", "user: [", "invalid config file"},
+		{"config.yaml", "unknown: 1", "invalid config file"},
+		{"config.json", `{"unknown": 1}`, "invalid config file"},
+		{"config.json", `{"user": 1}`, "invalid config file"},
+		{"config.yaml", "attempt_timeout: 2", "invalid duration for attempt_timeout"},
+		{"config.yml", "retry: {min_delay: x}", "invalid duration for retry.min_delay"},
+		{"config.json", `{"retry": {"max_delay": "-"}}`, "invalid duration for retry.max_delay"},
+		{"config.yaml", "keep_alive: 1", "invalid duration for keep_alive"},
+		{"config.yaml", "max_in_flight: -1", "invalid max in flight"},
+		{"config.yaml", "log_level: loud", "invalid log level"},
+	}
+	for _, tt := range tests {
+		filename := writeConfigFile(t, tt.name, tt.content)
+		err := new(Config).LoadFile(filename)
+		os.RemoveAll(filepath.Dir(filename))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("LoadFile(%s: %q) = %v, want an error with %q", tt.name, tt.content, err, tt.wantErr)
+		}
+	}
+
+	err := new(Config).LoadFile(filepath.Join(os.TempDir(), "yaks-no-such-config.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+		t.Errorf("LoadFile of a missing file = %v", err)
+	}
+}
+
+// withEnv runs f with only the given YAKS_* environment variables set
+func withEnv(t *testing.T, env map[string]string, f func()) {
+	t.Helper()
+	saved := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, envPrefix) {
+			i := strings.Index(kv, "=")
+			saved[kv[:i]] = kv[i+1:]
+			os.Unsetenv(kv[:i])
+		}
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+		for k, v := range saved {
+			os.Setenv(k, v)
+		}
+	}()
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	f()
+}
+
+func TestConfigLoadEnv(t *testing.T) {
+	withEnv(t, map[string]string{
+		"YAKS_LOCATORS":         " tcp/1.2.3.4:7447, ,tcp/5.6.7.8:7447",
+		"YAKS_USER":             "u",
+		"YAKS_RETRY_MAX_ROUNDS": "2",
+		"YAKS_KEEP_ALIVE":       "-1s",
+		"YAKS_EXECUTOR":         "true",
+		"YAKS_MAX_IN_FLIGHT":    "4",
+	}, func() {
+		c, err := NewConfig(WithCredentials("x", "p"), WithEnv())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c.Locators, []string{"tcp/1.2.3.4:7447", "tcp/5.6.7.8:7447"}) ||
+			c.User != "u" || c.Password != "p" || c.Retry.MaxRounds != 2 || c.KeepAlive != -time.Second ||
+			!c.Executor || c.MaxInFlight != 4 {
+			t.Errorf("config = %+v", c)
+		}
+	})
+}
+
+func TestConfigLoadEnvErrors(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    string
+	}{
+		{"YAKS_UNKNOWN", "1", "unknown environment variable: YAKS_UNKNOWN"},
+		{"YAKS_ATTEMPT_TIMEOUT", "1", "invalid duration for YAKS_ATTEMPT_TIMEOUT"},
+		{"YAKS_RETRY_MAX_ROUNDS", "x", "invalid integer for YAKS_RETRY_MAX_ROUNDS"},
+		{"YAKS_RETRY_MIN_DELAY", "x", "invalid duration for YAKS_RETRY_MIN_DELAY"},
+		{"YAKS_RETRY_MAX_DELAY", "x", "invalid duration for YAKS_RETRY_MAX_DELAY"},
+		{"YAKS_KEEP_ALIVE", "x", "invalid duration for YAKS_KEEP_ALIVE"},
+		{"YAKS_EXECUTOR", "maybe", "invalid boolean for YAKS_EXECUTOR"},
+		{"YAKS_MAX_IN_FLIGHT", "x", "invalid integer for YAKS_MAX_IN_FLIGHT"},
+		{"YAKS_MAX_IN_FLIGHT", "-2", "invalid max in flight"},
+		{"YAKS_LOG_LEVEL", "loud", "invalid log level"},
+	}
+	for _, tt := range tests {
+		withEnv(t, map[string]string{tt.key: tt.value}, func() {
+			if _, err := NewConfig(WithEnv()); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s=%s: error %v, want %q", tt.key, tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package yaks_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/memory"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+// blockingTransport is a session with a memory.Engine whose declarations of subscribers and evals
+// block until release is closed (signaling declaring when they start), and whose undeclarations
+// are signaled on undeclared
+type blockingTransport struct {
+	yaks.Transport
+	declaring  chan struct{}
+	release    chan struct{}
+	undeclared chan struct{}
+}
+
+func newBlockingYaks(t *testing.T, e *memory.Engine) (*yaks.Yaks, *blockingTransport) {
+	t.Helper()
+	tr := &blockingTransport{e.NewTransport(), make(chan struct{}, 1), make(chan struct{}), make(chan struct{}, 1)}
+	y, err := yaks.NewWithTransport(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return y, tr
+}
+
+func (t *blockingTransport) DeclareSubscriber(path string, handler yaks.SampleHandler) (yaks.TransportSubscriber, error) {
+	t.declaring <- struct{}{}
+	<-t.release
+	return t.Transport.DeclareSubscriber(path, handler)
+}
+
+func (t *blockingTransport) UndeclareSubscriber(sub yaks.TransportSubscriber) error {
+	defer func() { t.undeclared <- struct{}{} }()
+	return t.Transport.UndeclareSubscriber(sub)
+}
+
+func (t *blockingTransport) DeclareEval(path string, handler yaks.QueryHandler) (yaks.TransportEval, error) {
+	t.declaring <- struct{}{}
+	<-t.release
+	return t.Transport.DeclareEval(path, handler)
+}
+
+func (t *blockingTransport) UndeclareEval(eval yaks.TransportEval) error {
+	defer func() { t.undeclared <- struct{}{} }()
+	return t.Transport.UndeclareEval(eval)
+}
+
+// cancelWhenDeclaring cancels the context once tr starts a declaration, then lets it complete
+func cancelWhenDeclaring(tr *blockingTransport, cancel context.CancelFunc) {
+	<-tr.declaring
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(tr.release)
+}
+
+func awaitUndeclared(t *testing.T, tr *blockingTransport) {
+	t.Helper()
+	select {
+	case <-tr.undeclared:
+	case <-time.After(5 * time.Second):
+		t.Fatal("late declaration not undeclared")
+	}
+}
+
+func TestGetContext(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	// an eval that replies only once block is closed
+	s.Engine().SetEvalTimeout(time.Minute)
+	block := make(chan struct{})
+	defer close(block)
+	router := s.Engine().NewTransport()
+	defer router.Close()
+	router.DeclareEval("/e", func(string, string, yaks.RepliesSender) { <-block })
+	w := s.Workspace("/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := w.GetContext(ctx, selector(t, "/e"))
+	if !errors.Is(err, yaks.ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetContext after the deadline: %v, want ErrTimeout", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err = w.GetContext(ctx, selector(t, "/e"))
+	if !errors.Is(err, context.Canceled) || errors.Is(err, yaks.ErrTimeout) {
+		t.Errorf("cancelled GetContext: %v, want context.Canceled", err)
+	}
+	var yerr *yaks.YError
+	if !errors.As(err, &yerr) || yerr.Op != "Get" || yerr.Path != "/e" {
+		t.Errorf("cancelled GetContext: %#v, want a YError on Get /e", err)
+	}
+}
+
+func TestWriteContext(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	w := s.Workspace("/")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := w.PutContext(ctx, path(t, "/a/x"), yaks.NewStringValue("1")); !errors.Is(err, context.Canceled) {
+		t.Errorf("PutContext with a cancelled context: %v", err)
+	}
+	if err := w.UpdateContext(ctx, path(t, "/a/x"), yaks.NewStringValue("1")); !errors.Is(err, context.Canceled) {
+		t.Errorf("UpdateContext with a cancelled context: %v", err)
+	}
+	if err := w.RemoveContext(ctx, path(t, "/a/x")); !errors.Is(err, context.Canceled) {
+		t.Errorf("RemoveContext with a cancelled context: %v", err)
+	}
+	batch := yaks.NewBatch().Put(path(t, "/a/x"), yaks.NewStringValue("1"))
+	if _, err := w.PutBatchContext(ctx, batch); !errors.Is(err, context.Canceled) {
+		t.Errorf("PutBatchContext with a cancelled context: %v", err)
+	}
+	// nothing is written
+	if ops := s.Ops(); len(ops) != 0 {
+		t.Errorf("writes with a cancelled context recorded: %+v", ops)
+	}
+
+	if err := w.PutContext(context.Background(), path(t, "/a/x"), yaks.NewStringValue("1")); err != nil {
+		t.Fatal(err)
+	}
+	s.AssertPut("/a/x", yaks.NewStringValue("1"))
+}
+
+func TestSubscribeContext(t *testing.T) {
+	e := memory.NewEngine()
+	y, tr := newBlockingYaks(t, e)
+	defer y.Logout()
+	w := y.Workspace(path(t, "/"))
+	c := newChanges()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancelWhenDeclaring(tr, cancel)
+	if _, err := w.SubscribeContext(ctx, selector(t, "/a/*"), c.listener); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SubscribeContext cancelled during the declaration: %v", err)
+	}
+	// the subscriber declared after the cancellation is withdrawn
+	awaitUndeclared(t, tr)
+	other := e.NewTransport()
+	defer other.Close()
+	other.WriteDataWO("/a/x", []byte("1"), yaks.STRING, yaks.PUT)
+	select {
+	case <-c.notified:
+		t.Error("change notified to a subscription whose context was cancelled")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRegisterEvalContext(t *testing.T) {
+	e := memory.NewEngine()
+	y, tr := newBlockingYaks(t, e)
+	defer y.Logout()
+	w := y.Workspace(path(t, "/"))
+	eval := func(*yaks.Path, yaks.Properties) yaks.Value { return yaks.NewStringValue("e") }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancelWhenDeclaring(tr, cancel)
+	if err := w.RegisterEvalContext(ctx, path(t, "/e"), eval); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RegisterEvalContext cancelled during the declaration: %v", err)
+	}
+	// the eval declared after the cancellation is withdrawn
+	awaitUndeclared(t, tr)
+	if entries, _ := w.GetWithOptions(selector(t, "/e"), nil); len(entries) != 0 {
+		t.Errorf("eval whose context was cancelled replied %v", entries)
+	}
+	if err := w.UnregisterEval(path(t, "/e")); !errors.Is(err, yaks.ErrNotFound) {
+		t.Errorf("UnregisterEval of the withdrawn eval: %v, want ErrNotFound", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/atolab/yaks-go"
+	_ "github.com/atolab/yaks-go/zenohtransport"
 )
 
 func main() {
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/atolab/yaks-go"
+	_ "github.com/atolab/yaks-go/zenohtransport"
 )
 
 func main() {
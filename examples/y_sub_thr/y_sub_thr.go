@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/atolab/yaks-go"
+	_ "github.com/atolab/yaks-go/zenohtransport"
 )
 
 const n = 100000
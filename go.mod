@@ -1,10 +1,10 @@
 module github.com/atolab/yaks-go
 
-go 1.12
+go 1.13
 
 require (
 	github.com/atolab/zenoh-go v0.3.0
 	github.com/sirupsen/logrus v1.4.2
 	golang.org/x/sys v0.0.0-20191128015809-6d18c012aee9 // indirect
-	gopkg.in/yaml.v2 v2.2.7 // indirect
+	gopkg.in/yaml.v2 v2.2.7
 )
@@ -0,0 +1,229 @@
+package yaks
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy defines how the attempts to establish a session with Yaks are repeated.
+// A round is an attempt on each locator of the list, in order.
+type RetryPolicy struct {
+	// MaxRounds is the maximum number of rounds performed at login (1 if not strictly positive).
+	// The rounds performed to re-establish a lost session are not limited.
+	MaxRounds int
+	// MinDelay is the delay before the 2nd round. It's doubled before each next round.
+	// If not set, DefaultRetryPolicy.MinDelay is used.
+	MinDelay time.Duration
+	// MaxDelay is the maximum delay between 2 rounds. If not set, DefaultRetryPolicy.MaxDelay is used.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by Login: a single round at login,
+// and an exponential backoff from 100ms to 10s between reconnection rounds.
+var DefaultRetryPolicy = RetryPolicy{1, 100 * time.Millisecond, 10 * time.Second}
+
+func (r *RetryPolicy) withDefaults() RetryPolicy {
+	result := *r
+	if result.MinDelay <= 0 {
+		result.MinDelay = DefaultRetryPolicy.MinDelay
+	}
+	if result.MaxDelay <= 0 {
+		result.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	return result
+}
+
+func (r *RetryPolicy) nextDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay < r.MinDelay {
+		delay = r.MinDelay
+	}
+	if delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+	return delay
+}
+
+// LoginOptions contains the options to establish a session with Yaks via LoginWithOptions or LoginWithDialer.
+type LoginOptions struct {
+	// Locators is the ordered list of the Zenoh locators to try (format: ``tcp/<ip>:<port>``).
+	// If empty, some dynamic discovery is performed.
+	Locators []string
+	// Properties contains the configuration to be used for this session (e.g. "user", "password"...). It can be nil.
+	Properties Properties
+	// AttemptTimeout is the maximum duration of a connection attempt on a locator (no limit if 0).
+	AttemptTimeout time.Duration
+	// Retry is the RetryPolicy to apply at login and when the session is lost.
+	Retry RetryPolicy
+	// KeepAlive is the period of the liveness checks of the session, if the Transport is a Pinger
+	// (DefaultKeepAlive if 0, no check if negative). A session not responding within this period is lost.
+	KeepAlive time.Duration
+}
+
+// DefaultKeepAlive is the default period of the liveness checks of the session with Yaks
+const DefaultKeepAlive = 10 * time.Second
+
+// Dialer establishes a Transport with Yaks via locator (or via some dynamic discovery if locator is nil),
+// using properties (e.g. "user", "password"...). It's called for each connection attempt: LoginWithDialer
+// tries the locators in turn, as per the RetryPolicy and the AttemptTimeout of the LoginOptions.
+// The zenohtransport package provides the Dialer for Zenoh (zenohtransport.Dial).
+type Dialer func(locator *string, properties Properties) (Transport, error)
+
+var (
+	defaultDialerMu sync.RWMutex
+	defaultDialer   Dialer
+)
+
+// SetDefaultDialer sets the Dialer used by Login, LoginWithOptions and Config.Login (with a nil Dialer).
+// Importing the zenohtransport package sets zenohtransport.Dial as the default Dialer.
+func SetDefaultDialer(dial Dialer) {
+	defaultDialerMu.Lock()
+	defer defaultDialerMu.Unlock()
+	defaultDialer = dial
+}
+
+func getDefaultDialer() (Dialer, error) {
+	defaultDialerMu.RLock()
+	defer defaultDialerMu.RUnlock()
+	if defaultDialer == nil {
+		return nil, &YError{Op: "Login", msg: "no default Dialer: import github.com/atolab/yaks-go/zenohtransport", kind: ErrUnsupported}
+	}
+	return defaultDialer, nil
+}
+
+// Login establishes a session with the Yaks instance reachable via provided Zenoh locator.
+// If the provided locator is nil, 'login' will perform some dynamic discovery and try to
+// establish the session automatically. When not nil, the locator must have the format:
+// "tcp/<ip>:<port>".
+// Properties contains the configuration to be used for this session (e.g. "user", "password"...). It can be nil.
+// The session is established via the default Dialer (see SetDefaultDialer), i.e. via Zenoh if the
+// zenohtransport package is imported.
+func Login(locator *string, properties Properties) (*Yaks, error) {
+	var locators []string
+	if locator != nil {
+		locators = []string{*locator}
+	}
+	return LoginWithOptions(&LoginOptions{Locators: locators, Properties: properties, Retry: DefaultRetryPolicy})
+}
+
+// LoginWithOptions establishes a session with the Yaks instance reachable via one of the locators
+// in options, as LoginWithDialer does with the default Dialer (see SetDefaultDialer).
+func LoginWithOptions(options *LoginOptions) (*Yaks, error) {
+	dial, err := getDefaultDialer()
+	if err != nil {
+		return nil, err
+	}
+	return LoginWithDialer(dial, options)
+}
+
+// LoginWithDialer establishes a session with Yaks via dial, trying the locators of options in turn,
+// according to the RetryPolicy. The locator that has been used can be retrieved with Yaks.Locator().
+// If the session is lost afterwards, the locators are tried again, starting with the last one that worked.
+func LoginWithDialer(dial Dialer, options *LoginOptions) (*Yaks, error) {
+	logger.WithField("locators", options.Locators).Debug("Connecting to Yaks")
+	opts := *options
+	opts.Retry = options.Retry.withDefaults()
+	options = &opts
+	redial := func(start int) (Transport, int, error) {
+		return dialLocators(dial, options, start)
+	}
+	delay := options.Retry.MinDelay
+	for round := 1; ; round++ {
+		t, idx, err := redial(0)
+		if err == nil {
+			y, err := newYaks(t, redial, idx, options)
+			if err != nil {
+				t.Close()
+				return nil, &YError{Op: "Login", cause: err}
+			}
+			return y, nil
+		}
+		if round >= options.Retry.MaxRounds {
+			return nil, &YError{Op: "Login", cause: err}
+		}
+		logger.WithFields(log.Fields{
+			"error": err,
+			"retry": delay,
+		}).Debug("Connection to Yaks failed")
+		time.Sleep(delay)
+		delay = options.Retry.nextDelay(delay)
+	}
+}
+
+// dialLocators tries each locator of options in turn with dial, starting from the one at index start,
+// until a Transport is established. It returns the Transport and the index of the locator used
+// (-1 if the locators are empty and dynamic discovery was used).
+func dialLocators(dial Dialer, options *LoginOptions, start int) (Transport, int, error) {
+	if len(options.Locators) == 0 {
+		t, err := dialWithTimeout(dial, nil, options.Properties, options.AttemptTimeout)
+		return t, -1, err
+	}
+	if start < 0 {
+		start = 0
+	}
+	var err error
+	for i := range options.Locators {
+		idx := (start + i) % len(options.Locators)
+		var t Transport
+		t, err = dialWithTimeout(dial, &options.Locators[idx], options.Properties, options.AttemptTimeout)
+		if err == nil {
+			return t, idx, nil
+		}
+		logger.WithFields(log.Fields{
+			"locator": options.Locators[idx],
+			"error":   err,
+		}).Debug("Connection attempt failed")
+	}
+	return nil, -1, err
+}
+
+// dialWithTimeout establishes a Transport with dial, giving up after timeout (if strictly positive):
+// the Transport is then closed as soon as it's established, and an error matching ErrTimeout is returned.
+func dialWithTimeout(dial Dialer, locator *string, properties Properties, timeout time.Duration) (Transport, error) {
+	if timeout <= 0 {
+		return dial(locator, properties)
+	}
+	type result struct {
+		t   Transport
+		err error
+	}
+	mu := new(sync.Mutex)
+	abandoned := false
+	dialed := make(chan result, 1)
+	go func() {
+		t, err := dial(locator, properties)
+		mu.Lock()
+		defer mu.Unlock()
+		if abandoned {
+			if err == nil {
+				t.Close()
+			}
+			return
+		}
+		dialed <- result{t, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-dialed:
+		return r.t, r.err
+	case <-timer.C:
+		mu.Lock()
+		defer mu.Unlock()
+		select {
+		case r := <-dialed:
+			// established while timing out
+			return r.t, r.err
+		default:
+		}
+		abandoned = true
+		target := "Yaks"
+		if locator != nil {
+			target = *locator
+		}
+		return nil, &YError{msg: "connecting to " + target + " after " + timeout.String(), kind: ErrTimeout}
+	}
+}
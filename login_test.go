@@ -0,0 +1,222 @@
+package yaks_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/memory"
+)
+
+// fakeDialer is a yaks.Dialer establishing sessions with a memory.Engine, on the locators that are up
+type fakeDialer struct {
+	e  *memory.Engine
+	mu sync.Mutex
+	// locators that can be dialed, with the delay of the connection
+	up       map[string]time.Duration
+	attempts []string
+	// the last Transport established
+	last yaks.Transport
+}
+
+func newFakeDialer(up map[string]time.Duration) *fakeDialer {
+	return &fakeDialer{e: memory.NewEngine(), up: up}
+}
+
+func (d *fakeDialer) dial(locator *string, properties yaks.Properties) (yaks.Transport, error) {
+	l := "<discovery>"
+	if locator != nil {
+		l = *locator
+	}
+	d.mu.Lock()
+	d.attempts = append(d.attempts, l)
+	delay, ok := d.up[l]
+	d.mu.Unlock()
+	if !ok {
+		return nil, errors.New(l + " unreachable")
+	}
+	time.Sleep(delay)
+	t := d.e.NewTransport()
+	d.mu.Lock()
+	d.last = t
+	d.mu.Unlock()
+	return t, nil
+}
+
+func (d *fakeDialer) setUp(locator string, up bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if up {
+		d.up[locator] = 0
+	} else {
+		delete(d.up, locator)
+	}
+}
+
+// takeAttempts returns the locators dialed since the previous call
+func (d *fakeDialer) takeAttempts() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	attempts := d.attempts
+	d.attempts = nil
+	return attempts
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLoginFailover(t *testing.T) {
+	d := newFakeDialer(map[string]time.Duration{"b": 0, "c": 0})
+	y, err := yaks.LoginWithDialer(d.dial, &yaks.LoginOptions{
+		Locators:  []string{"a", "b", "c"},
+		Retry:     yaks.RetryPolicy{MinDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		KeepAlive: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Logout()
+	if attempts := d.takeAttempts(); !equalStrings(attempts, []string{"a", "b"}) {
+		t.Errorf("locators tried at login: %v, want a then b", attempts)
+	}
+	if y.Locator() != "b" {
+		t.Errorf("Locator() = %q, want b", y.Locator())
+	}
+
+	// b goes down: the reconnection starts with b, then falls back to c
+	reconnected := make(chan struct{}, 1)
+	y.OnStateChange(func(old yaks.State, new yaks.State) {
+		if new == yaks.StateConnected {
+			reconnected <- struct{}{}
+		}
+	})
+	d.setUp("b", false)
+	d.mu.Lock()
+	d.last.Close()
+	d.mu.Unlock()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not re-established")
+	}
+	if attempts := d.takeAttempts(); !equalStrings(attempts, []string{"b", "c"}) {
+		t.Errorf("locators tried at reconnection: %v, want b then c", attempts)
+	}
+	if y.Locator() != "c" {
+		t.Errorf("Locator() after reconnection = %q, want c", y.Locator())
+	}
+}
+
+func TestLoginAttemptTimeout(t *testing.T) {
+	d := newFakeDialer(map[string]time.Duration{"slow": 100 * time.Millisecond, "fast": 0})
+	y, err := yaks.LoginWithDialer(d.dial, &yaks.LoginOptions{
+		Locators:       []string{"slow", "fast"},
+		AttemptTimeout: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Logout()
+	if y.Locator() != "fast" {
+		t.Errorf("Locator() = %q, want fast after the timeout on slow", y.Locator())
+	}
+	d.mu.Lock()
+	fast := d.last
+	d.mu.Unlock()
+	// the session established with slow after the timeout is closed
+	time.Sleep(200 * time.Millisecond)
+	d.mu.Lock()
+	late := d.last
+	d.mu.Unlock()
+	if late == fast {
+		t.Fatal("slow session not established after the timeout")
+	}
+	if err := late.(yaks.Pinger).Ping(time.Second); err == nil {
+		t.Error("session established after the timeout not closed")
+	}
+}
+
+func TestLoginRounds(t *testing.T) {
+	d := newFakeDialer(map[string]time.Duration{})
+	_, err := yaks.LoginWithDialer(d.dial, &yaks.LoginOptions{
+		Locators: []string{"a", "b"},
+		Retry:    yaks.RetryPolicy{MaxRounds: 2, MinDelay: time.Millisecond},
+	})
+	if err == nil {
+		t.Fatal("login succeeded without any locator up")
+	}
+	if attempts := d.takeAttempts(); !equalStrings(attempts, []string{"a", "b", "a", "b"}) {
+		t.Errorf("locators tried: %v, want 2 rounds on a and b", attempts)
+	}
+
+	_, err = yaks.LoginWithDialer(d.dial, &yaks.LoginOptions{
+		Locators:       []string{"a"},
+		AttemptTimeout: 10 * time.Millisecond,
+	})
+	if err == nil {
+		t.Error("login succeeded without any locator up")
+	}
+
+	d.setUp("<discovery>", true)
+	y, err := yaks.LoginWithDialer(d.dial, &yaks.LoginOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Logout()
+	if attempts := d.takeAttempts(); !equalStrings(attempts, []string{"a", "<discovery>"}) {
+		t.Errorf("locators tried: %v, want the discovery", attempts)
+	}
+	if y.Locator() != "" {
+		t.Errorf("Locator() = %q after discovery, want none", y.Locator())
+	}
+}
+
+func TestLoginTimeoutError(t *testing.T) {
+	d := newFakeDialer(map[string]time.Duration{"slow": 100 * time.Millisecond})
+	_, err := yaks.LoginWithDialer(d.dial, &yaks.LoginOptions{
+		Locators:       []string{"slow"},
+		AttemptTimeout: 10 * time.Millisecond,
+	})
+	if !errors.Is(err, yaks.ErrTimeout) {
+		t.Errorf("login timing out: %v, want ErrTimeout", err)
+	}
+}
+
+func TestLoginWithDefaultDialer(t *testing.T) {
+	defer yaks.SetDefaultDialer(nil)
+	locator := "a"
+	if _, err := yaks.Login(&locator, nil); !errors.Is(err, yaks.ErrUnsupported) {
+		t.Errorf("Login without default Dialer: %v, want ErrUnsupported", err)
+	}
+
+	d := newFakeDialer(map[string]time.Duration{"a": 0})
+	yaks.SetDefaultDialer(d.dial)
+	y, err := yaks.Login(&locator, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y.Logout()
+	config, err := yaks.NewConfig(yaks.WithLocators("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err = config.Login(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y.Logout()
+	if attempts := d.takeAttempts(); !equalStrings(attempts, []string{"a", "a"}) {
+		t.Errorf("locators dialed by the default Dialer: %v, want a twice", attempts)
+	}
+}
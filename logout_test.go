@@ -0,0 +1,105 @@
+package yaks_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/memory"
+)
+
+// blockedListener is a Listener blocking until release is closed
+type blockedListener struct {
+	started  chan struct{}
+	release  chan struct{}
+	finished int32
+	calls    int32
+}
+
+func newBlockedListener() *blockedListener {
+	return &blockedListener{started: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (l *blockedListener) listener([]yaks.Change) {
+	atomic.AddInt32(&l.calls, 1)
+	l.started <- struct{}{}
+	<-l.release
+	atomic.StoreInt32(&l.finished, 1)
+}
+
+// loginWithBlockedListener returns a Yaks on e, with a Workspace subscribed to /a/* with l,
+// whose listener is running once it returns
+func loginWithBlockedListener(t *testing.T, e *memory.Engine, l *blockedListener) (*yaks.Yaks, *yaks.Workspace) {
+	t.Helper()
+	y, err := e.Login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := y.WorkspaceWithExecutor(path(t, "/"))
+	if _, err := w.Subscribe(selector(t, "/a/*"), l.listener); err != nil {
+		t.Fatal(err)
+	}
+	e.NewTransport().WriteDataWO("/a/x", []byte("1"), yaks.STRING, yaks.PUT)
+	select {
+	case <-l.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener not called")
+	}
+	return y, w
+}
+
+func TestLogoutWaitsForCallbacks(t *testing.T) {
+	e := memory.NewEngine()
+	l := newBlockedListener()
+	y, _ := loginWithBlockedListener(t, e, l)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		close(l.release)
+	}()
+	if err := y.LogoutWithTimeout(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&l.finished) != 1 {
+		t.Error("Logout returned before the completion of the running listener")
+	}
+}
+
+func TestLogoutTimeout(t *testing.T) {
+	e := memory.NewEngine()
+	l := newBlockedListener()
+	defer close(l.release)
+	y, w := loginWithBlockedListener(t, e, l)
+
+	start := time.Now()
+	err := y.LogoutWithTimeout(50 * time.Millisecond)
+	if !errors.Is(err, yaks.ErrTimeout) {
+		t.Errorf("Logout with a blocked listener: %v, want ErrTimeout", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("Logout returned after %s, before its timeout", d)
+	}
+	if y.State() != yaks.StateClosed {
+		t.Errorf("state after Logout = %v, want closed", y.State())
+	}
+
+	// the subscriber is undeclared, and the later calls fail
+	e.NewTransport().WriteDataWO("/a/y", []byte("2"), yaks.STRING, yaks.PUT)
+	time.Sleep(20 * time.Millisecond)
+	if calls := atomic.LoadInt32(&l.calls); calls != 1 {
+		t.Errorf("listener called %d times, want once (before Logout)", calls)
+	}
+	if err := w.Put(path(t, "/a/x"), yaks.NewStringValue("1")); !errors.Is(err, yaks.ErrClosed) {
+		t.Errorf("Put after Logout: %v, want ErrClosed", err)
+	}
+	if _, err := w.Subscribe(selector(t, "/a/*"), l.listener); !errors.Is(err, yaks.ErrClosed) {
+		t.Errorf("Subscribe after Logout: %v, want ErrClosed", err)
+	}
+	if err := w.RegisterEval(path(t, "/e"), nil); !errors.Is(err, yaks.ErrClosed) {
+		t.Errorf("RegisterEval after Logout: %v, want ErrClosed", err)
+	}
+	if err := y.Logout(); err != nil {
+		t.Errorf("second Logout: %v", err)
+	}
+}
@@ -0,0 +1,362 @@
+// Package memory provides an in-process Yaks engine, implementing yaks.Transport.
+// It allows to use Workspace and Admin without a Zenoh router (e.g. in tests or examples):
+// the storages added via Admin keep the latest value of each path in memory (merging the updates
+// as per yaks.Merge), and the puts, updates, removes, subscriptions, gets and evals are handled locally.
+package memory
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// BackendID is the id of the backend in which the storages of an Engine are created
+// (including the storages created on the "auto" backend).
+const BackendID = "Memory"
+
+// Locator is the locator reported in the SessionInfo of the sessions with an Engine.
+const Locator = "memory"
+
+// DefaultEvalTimeout is the default maximum duration an Engine waits for the replies of an eval to a query.
+const DefaultEvalTimeout = 10 * time.Second
+
+// Engine is an in-process Yaks engine. Each session created with NewTransport or Login
+// shares the storages of the Engine.
+type Engine struct {
+	mu       sync.Mutex
+	id       [16]byte
+	yaksid   string
+	lastTime time.Time
+	admin    map[string]*entry
+	storages map[string]*storage
+	subs     map[*subscriber]struct{}
+	evals    map[*eval]struct{}
+	// evalTimeout is the maximum duration to wait for the replies of an eval
+	evalTimeout time.Duration
+}
+
+// entry is a value stored by an Engine
+type entry struct {
+	data     []byte
+	encoding yaks.Encoding
+	ts       yaks.Timestamp
+}
+
+// storage is a storage created via the admin space of an Engine
+type storage struct {
+	id       string
+	selector string
+	entries  map[string]*entry
+}
+
+// subscriber is a subscriber declared on an Engine
+type subscriber struct {
+	s       *session
+	path    string
+	handler yaks.SampleHandler
+}
+
+// eval is an eval declared on an Engine
+type eval struct {
+	s       *session
+	path    string
+	handler yaks.QueryHandler
+}
+
+// NewEngine returns a new Engine, with no storage.
+func NewEngine() *Engine {
+	e := &Engine{
+		id:          newID(),
+		admin:       make(map[string]*entry),
+		storages:    make(map[string]*storage),
+		subs:        make(map[*subscriber]struct{}),
+		evals:       make(map[*eval]struct{}),
+		evalTimeout: DefaultEvalTimeout,
+	}
+	e.yaksid = hex.EncodeToString(e.id[:])
+	e.admin[e.backendPath(BackendID)] = &entry{[]byte("kind=memory"), yaks.PROPERTIES, e.newTimestamp()}
+	return e
+}
+
+func newID() [16]byte {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic("failed to generate a random id: " + err.Error())
+	}
+	return id
+}
+
+// ID returns the Yaks id of the Engine (i.e. the one used in its admin space).
+func (e *Engine) ID() string {
+	return e.yaksid
+}
+
+// SetEvalTimeout sets the maximum duration the Engine waits for the replies of the evals to a query
+// (DefaultEvalTimeout by default). The evals that didn't reply in time are considered as having no reply.
+func (e *Engine) SetEvalTimeout(timeout time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.evalTimeout = timeout
+}
+
+// NewTransport returns a new session with the Engine.
+func (e *Engine) NewTransport() yaks.Transport {
+	return &session{e: e, id: newID()}
+}
+
+// Login returns a Yaks using a new session with the Engine.
+func (e *Engine) Login() (*yaks.Yaks, error) {
+	return yaks.NewWithTransport(e.NewTransport())
+}
+
+// Login returns a Yaks using a session with a new Engine.
+func Login() (*yaks.Yaks, error) {
+	return NewEngine().Login()
+}
+
+func (e *Engine) backendPath(beid string) string {
+	return "/@/" + e.yaksid + "/plugins/yaks/backend/" + beid
+}
+
+// newTimestamp returns a new Timestamp, always greater than the previous ones. e.mu must be locked.
+func (e *Engine) newTimestamp() yaks.Timestamp {
+	now := time.Now()
+	if !now.After(e.lastTime) {
+		now = e.lastTime.Add(time.Nanosecond)
+	}
+	e.lastTime = now
+	return yaks.NewTimestamp(now, e.id)
+}
+
+// write writes the samples with the same timestamp and notifies the matching subscribers.
+// If batchID is not empty, each subscriber receives all its matching samples as a batch.
+func (e *Engine) write(batchID string, samples []yaks.Sample) {
+	e.mu.Lock()
+	ts := e.newTimestamp()
+	for i := range samples {
+		s := &samples[i]
+		s.Timestamp = ts
+		s.BatchID = batchID
+		ent := &entry{s.Data, s.Encoding, ts}
+		if strings.HasPrefix(s.Path, "/@/") {
+			e.writeAdmin(s.Path, ent, s.Kind)
+		} else {
+			for _, st := range e.storages {
+				if Intersect(s.Path, st.selector) {
+					st.write(s.Path, ent, s.Kind)
+				}
+			}
+		}
+	}
+	notifs := make(map[*subscriber][]yaks.Sample)
+	for sub := range e.subs {
+		for _, s := range samples {
+			if Intersect(s.Path, sub.path) {
+				notifs[sub] = append(notifs[sub], s)
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	for sub, samples := range notifs {
+		for i := range samples {
+			if len(batchID) > 0 {
+				samples[i].BatchSize = len(samples)
+			}
+			sub.handler(&samples[i])
+		}
+	}
+}
+
+func (st *storage) write(path string, ent *entry, kind yaks.ChangeKind) {
+	switch kind {
+	case yaks.REMOVE:
+		delete(st.entries, path)
+	case yaks.UPDATE:
+		st.entries[path] = mergeEntries(st.entries[path], ent)
+	default:
+		st.entries[path] = ent
+	}
+}
+
+// mergeEntries returns the entry resulting of the update of base (possibly nil) with delta, as per yaks.Merge
+func mergeEntries(base *entry, delta *entry) *entry {
+	d, err := yaks.DecodeValue(delta.encoding, delta.data)
+	if err != nil {
+		return delta
+	}
+	var b yaks.Value
+	if base != nil {
+		if b, err = yaks.DecodeValue(base.encoding, base.data); err != nil {
+			return delta
+		}
+	}
+	merged, err := yaks.Merge(b, d)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to merge an update: the update replaces the value")
+		return delta
+	}
+	return &entry{merged.Encode(), merged.Encoding(), delta.ts}
+}
+
+// writeAdmin handles a write in the admin space. Only the backends and storages of this Engine
+// can be added or removed. e.mu must be locked.
+func (e *Engine) writeAdmin(path string, ent *entry, kind yaks.ChangeKind) {
+	prefix := e.backendPath("")
+	if !strings.HasPrefix(path, prefix) {
+		return
+	}
+	chunks := strings.Split(path[len(prefix):], "/")
+	switch {
+	case len(chunks) == 1 && len(chunks[0]) > 0:
+		if kind == yaks.REMOVE {
+			delete(e.admin, path)
+		} else {
+			e.admin[path] = ent
+		}
+	case len(chunks) == 3 && chunks[1] == "storage" && len(chunks[2]) > 0:
+		beid, stid := chunks[0], chunks[2]
+		if beid == "auto" {
+			beid = BackendID
+		}
+		if _, ok := e.admin[e.backendPath(beid)]; !ok {
+			logger.WithField("path", path).Warn("Storage on unknown backend " + beid + " is ignored")
+			return
+		}
+		stPath := e.backendPath(beid) + "/storage/" + stid
+		if kind == yaks.REMOVE {
+			delete(e.admin, stPath)
+			delete(e.storages, stPath)
+			return
+		}
+		selector, ok := parseProperties(string(ent.data))["selector"]
+		if !ok {
+			logger.WithField("path", path).Warn("Storage without selector property is ignored")
+			return
+		}
+		e.admin[stPath] = ent
+		if st, ok := e.storages[stPath]; ok && st.selector == selector {
+			return
+		}
+		e.storages[stPath] = &storage{stid, selector, make(map[string]*entry)}
+	}
+}
+
+func parseProperties(s string) map[string]string {
+	p := make(map[string]string)
+	if len(s) > 0 {
+		for _, kv := range strings.Split(s, ";") {
+			i := strings.Index(kv, "=")
+			if i < 0 {
+				p[kv] = ""
+			} else {
+				p[kv[:i]] = kv[i+1:]
+			}
+		}
+	}
+	return p
+}
+
+// storageReplies are the replies of a storage to a query
+type storageReplies struct {
+	id      []byte
+	replies []*yaks.Reply
+}
+
+func (e *Engine) query(path string, predicate string, handler yaks.ReplyHandler) {
+	e.mu.Lock()
+	var fromStorages []storageReplies
+	if strings.HasPrefix(path, "/@/") {
+		fromStorages = append(fromStorages, storageReplies{[]byte("admin"), repliesOf(path, e.admin)})
+	} else {
+		for _, st := range e.storages {
+			if Intersect(path, st.selector) {
+				fromStorages = append(fromStorages, storageReplies{[]byte(st.id), repliesOf(path, st.entries)})
+			}
+		}
+	}
+	var evals []*eval
+	for ev := range e.evals {
+		if Intersect(path, ev.path) {
+			evals = append(evals, ev)
+		}
+	}
+	evalTimeout := e.evalTimeout
+	e.mu.Unlock()
+
+	for _, sr := range fromStorages {
+		for _, r := range sr.replies {
+			r.SourceID = sr.id
+			handler(r)
+		}
+		handler(&yaks.Reply{Kind: yaks.ReplyStorageFinal, SourceID: sr.id})
+	}
+
+	for i, replies := range e.callEvals(evals, path, predicate, evalTimeout) {
+		id := []byte(evals[i].path)
+		for _, r := range replies {
+			e.mu.Lock()
+			ts := e.newTimestamp()
+			e.mu.Unlock()
+			handler(&yaks.Reply{Kind: yaks.ReplyEvalData, SourceID: id, Path: r.Path, Data: r.Data, Encoding: r.Encoding, Timestamp: ts})
+		}
+		handler(&yaks.Reply{Kind: yaks.ReplyEvalFinal, SourceID: id})
+	}
+
+	handler(&yaks.Reply{Kind: yaks.ReplyFinal})
+}
+
+// evalReplies are the replies of the eval at index i to a query
+type evalReplies struct {
+	i       int
+	replies []yaks.Sample
+}
+
+// callEvals calls the evals concurrently, as they might reply asynchronously, and returns their replies.
+// The evals that don't reply within timeout have no reply (their late replies are ignored).
+func (e *Engine) callEvals(evals []*eval, path string, predicate string, timeout time.Duration) [][]yaks.Sample {
+	results := make([][]yaks.Sample, len(evals))
+	if len(evals) == 0 {
+		return results
+	}
+	// buffered, so that the late replies don't block
+	replied := make(chan evalReplies, len(evals))
+	for i, ev := range evals {
+		i := i
+		var once sync.Once
+		go ev.handler(path, predicate, func(replies []yaks.Sample) {
+			once.Do(func() { replied <- evalReplies{i, replies} })
+		})
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for n := 0; n < len(evals); n++ {
+		select {
+		case r := <-replied:
+			results[r.i] = r.replies
+		case <-timer.C:
+			logger.WithFields(log.Fields{
+				"path":    path,
+				"missing": len(evals) - n,
+			}).Warn("Query: some evals didn't reply in time")
+			return results
+		}
+	}
+	return results
+}
+
+func repliesOf(path string, entries map[string]*entry) []*yaks.Reply {
+	var replies []*yaks.Reply
+	for p, ent := range entries {
+		if Intersect(p, path) {
+			replies = append(replies, &yaks.Reply{
+				Kind: yaks.ReplyStorageData, Path: p, Data: ent.data, Encoding: ent.encoding, Timestamp: ent.ts})
+		}
+	}
+	return replies
+}
@@ -0,0 +1,300 @@
+package memory
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+)
+
+// addStorage adds a storage with selector to e via the admin space, as Admin.AddStorage does
+func addStorage(t *testing.T, tr yaks.Transport, e *Engine, stid string, selector string) {
+	t.Helper()
+	path := e.backendPath(BackendID) + "/storage/" + stid
+	if err := tr.WriteDataWO(path, []byte("selector="+selector), yaks.PROPERTIES, yaks.PUT); err != nil {
+		t.Fatalf("adding storage %s: %v", stid, err)
+	}
+}
+
+// query sends a query and returns all its replies, the last one being of kind ReplyFinal
+func query(t *testing.T, tr yaks.Transport, path string) []*yaks.Reply {
+	t.Helper()
+	replies := make(chan *yaks.Reply, 100)
+	if err := tr.Query(path, "", func(r *yaks.Reply) { replies <- r }); err != nil {
+		t.Fatalf("query %s: %v", path, err)
+	}
+	var result []*yaks.Reply
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r := <-replies:
+			result = append(result, r)
+			if r.Kind == yaks.ReplyFinal {
+				return result
+			}
+		case <-timeout:
+			t.Fatalf("query %s: no final reply", path)
+		}
+	}
+}
+
+// dataOf returns the data of the replies of kind, per path
+func dataOf(replies []*yaks.Reply, kind yaks.ReplyKind) map[string]string {
+	data := make(map[string]string)
+	for _, r := range replies {
+		if r.Kind == kind {
+			data[r.Path] = string(r.Data)
+		}
+	}
+	return data
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/a/b", "/a/b", true},
+		{"/a/b", "/a/c", false},
+		{"/a/b", "/a/*", true},
+		{"/a/b/c", "/a/*", false},
+		{"/a/b/c", "/a/**", true},
+		{"/a", "/a/**", true},
+		{"/a/b/c", "/**/c", true},
+		{"/a/bcd", "/a/b*d", true},
+		{"/a/bce", "/a/b*d", false},
+		{"/a/*/c", "/a/b/*", true},
+		{"/a/**", "/b/**", false},
+	}
+	for _, tt := range tests {
+		if got := Intersect(tt.a, tt.b); got != tt.want {
+			t.Errorf("Intersect(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Intersect(tt.b, tt.a); got != tt.want {
+			t.Errorf("Intersect(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	e := NewEngine()
+	tr := e.NewTransport()
+	defer tr.Close()
+	addStorage(t, tr, e, "st", "/a/**")
+
+	tr.WriteDataWO("/a/b", []byte("1"), yaks.STRING, yaks.PUT)
+	tr.WriteDataWO("/a/c", []byte("2"), yaks.STRING, yaks.PUT)
+	tr.WriteDataWO("/x/y", []byte("3"), yaks.STRING, yaks.PUT)
+	tr.WriteDataWO("/a/b", []byte("4"), yaks.STRING, yaks.PUT)
+	tr.WriteDataWO("/a/c", nil, 0, yaks.REMOVE)
+
+	replies := query(t, tr, "/**")
+	got := dataOf(replies, yaks.ReplyStorageData)
+	if len(got) != 1 || got["/a/b"] != "4" {
+		t.Errorf("storage data = %v, want map[/a/b:4]", got)
+	}
+	var finals int
+	for _, r := range replies {
+		if r.Kind == yaks.ReplyStorageFinal {
+			finals++
+			if string(r.SourceID) != "st" {
+				t.Errorf("storage final from %q, want st", r.SourceID)
+			}
+		}
+	}
+	if finals != 1 {
+		t.Errorf("%d storage finals, want 1", finals)
+	}
+}
+
+func TestStorageTimestamps(t *testing.T) {
+	e := NewEngine()
+	tr := e.NewTransport()
+	defer tr.Close()
+	addStorage(t, tr, e, "st", "/a/**")
+	tr.WriteDataWO("/a/b", []byte("1"), yaks.STRING, yaks.PUT)
+	tr.WriteDataWO("/a/c", []byte("2"), yaks.STRING, yaks.PUT)
+
+	var ts []yaks.Timestamp
+	for _, r := range query(t, tr, "/a/*") {
+		if r.Kind == yaks.ReplyStorageData {
+			ts = append(ts, r.Timestamp)
+		}
+	}
+	if len(ts) != 2 {
+		t.Fatalf("%d replies, want 2", len(ts))
+	}
+	if !ts[0].Before(&ts[1]) && !ts[1].Before(&ts[0]) {
+		t.Errorf("writes have the same timestamp %v", ts[0])
+	}
+}
+
+func TestStorageUpdateMerges(t *testing.T) {
+	e := NewEngine()
+	tr := e.NewTransport()
+	defer tr.Close()
+	addStorage(t, tr, e, "st", "/a/**")
+
+	tr.WriteDataWO("/a/p", []byte("x=1;y=2"), yaks.PROPERTIES, yaks.PUT)
+	tr.WriteDataWO("/a/p", []byte("y=3;z=4"), yaks.PROPERTIES, yaks.UPDATE)
+	// an update of a path without value is stored as is
+	tr.WriteDataWO("/a/q", []byte("z=5"), yaks.PROPERTIES, yaks.UPDATE)
+
+	got := dataOf(query(t, tr, "/a/*"), yaks.ReplyStorageData)
+	props := parseProperties(got["/a/p"])
+	if len(props) != 3 || props["x"] != "1" || props["y"] != "3" || props["z"] != "4" {
+		t.Errorf("/a/p = %v, want x=1;y=3;z=4", props)
+	}
+	if got["/a/q"] != "z=5" {
+		t.Errorf("/a/q = %q, want z=5", got["/a/q"])
+	}
+}
+
+func TestAdminStorageOnUnknownBackend(t *testing.T) {
+	e := NewEngine()
+	tr := e.NewTransport()
+	defer tr.Close()
+	path := e.backendPath("Unknown") + "/storage/st"
+	tr.WriteDataWO(path, []byte("selector=/a/**"), yaks.PROPERTIES, yaks.PUT)
+	tr.WriteDataWO("/a/b", []byte("1"), yaks.STRING, yaks.PUT)
+	if got := dataOf(query(t, tr, "/a/**"), yaks.ReplyStorageData); len(got) != 0 {
+		t.Errorf("data stored without storage: %v", got)
+	}
+	if got := dataOf(query(t, tr, "/@/**"), yaks.ReplyStorageData); len(got) != 1 {
+		t.Errorf("admin space = %v, want only the Memory backend", got)
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	e := NewEngine()
+	tr := e.NewTransport()
+	defer tr.Close()
+	samples := make(chan yaks.Sample, 10)
+	sub, err := tr.DeclareSubscriber("/a/*", func(s *yaks.Sample) { samples <- *s })
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.WriteDataWO("/a/b", []byte("1"), yaks.STRING, yaks.PUT)
+	tr.WriteDataWO("/x/y", []byte("2"), yaks.STRING, yaks.PUT)
+	tr.WriteBatch("batch", []yaks.Sample{
+		{Path: "/a/c", Data: []byte("3"), Encoding: yaks.STRING, Kind: yaks.PUT},
+		{Path: "/x/z", Data: []byte("4"), Encoding: yaks.STRING, Kind: yaks.PUT},
+		{Path: "/a/d", Kind: yaks.REMOVE},
+	})
+
+	s := <-samples
+	if s.Path != "/a/b" || string(s.Data) != "1" || s.BatchID != "" || s.BatchSize != 0 {
+		t.Errorf("first sample = %+v", s)
+	}
+	for _, want := range []string{"/a/c", "/a/d"} {
+		s := <-samples
+		if s.Path != want || s.BatchID != "batch" || s.BatchSize != 2 {
+			t.Errorf("batch sample = %+v, want %s in a batch of 2", s, want)
+		}
+	}
+
+	if err := tr.UndeclareSubscriber(sub); err != nil {
+		t.Fatal(err)
+	}
+	tr.WriteDataWO("/a/b", []byte("5"), yaks.STRING, yaks.PUT)
+	select {
+	case s := <-samples:
+		t.Errorf("sample received after undeclaration: %+v", s)
+	default:
+	}
+}
+
+func TestEval(t *testing.T) {
+	e := NewEngine()
+	tr := e.NewTransport()
+	defer tr.Close()
+	_, err := tr.DeclareEval("/e/*", func(path string, predicate string, send yaks.RepliesSender) {
+		// reply asynchronously
+		go send([]yaks.Sample{{Path: "/e/x", Data: []byte(predicate), Encoding: yaks.STRING}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replies := make(chan *yaks.Reply, 10)
+	tr.Query("/e/x", "arg", func(r *yaks.Reply) { replies <- r })
+	var kinds []yaks.ReplyKind
+	for r := range replies {
+		kinds = append(kinds, r.Kind)
+		if r.Kind == yaks.ReplyEvalData && (r.Path != "/e/x" || string(r.Data) != "arg") {
+			t.Errorf("eval reply = %+v", r)
+		}
+		if r.Kind == yaks.ReplyFinal {
+			break
+		}
+	}
+	want := []yaks.ReplyKind{yaks.ReplyEvalData, yaks.ReplyEvalFinal, yaks.ReplyFinal}
+	if len(kinds) != len(want) {
+		t.Fatalf("reply kinds = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("reply kinds = %v, want %v", kinds, want)
+		}
+	}
+}
+
+func TestEvalTimeout(t *testing.T) {
+	e := NewEngine()
+	e.SetEvalTimeout(50 * time.Millisecond)
+	tr := e.NewTransport()
+	defer tr.Close()
+	// an eval that never replies, and one that replies
+	tr.DeclareEval("/e/silent", func(string, string, yaks.RepliesSender) {})
+	tr.DeclareEval("/e/*", func(path string, predicate string, send yaks.RepliesSender) {
+		send([]yaks.Sample{{Path: "/e/ok", Data: []byte("ok"), Encoding: yaks.STRING}})
+	})
+
+	start := time.Now()
+	replies := query(t, tr, "/e/**")
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("query took %s despite the eval timeout", d)
+	}
+	var finals []string
+	for _, r := range replies {
+		if r.Kind == yaks.ReplyEvalFinal {
+			finals = append(finals, string(r.SourceID))
+		}
+	}
+	sort.Strings(finals)
+	if len(finals) != 2 || finals[0] != "/e/*" || finals[1] != "/e/silent" {
+		t.Errorf("eval finals from %v, want both evals", finals)
+	}
+	if got := dataOf(replies, yaks.ReplyEvalData); len(got) != 1 || got["/e/ok"] != "ok" {
+		t.Errorf("eval data = %v, want map[/e/ok:ok]", got)
+	}
+}
+
+func TestClosedSession(t *testing.T) {
+	e := NewEngine()
+	tr := e.NewTransport()
+	samples := make(chan *yaks.Sample, 1)
+	other := e.NewTransport()
+	defer other.Close()
+	tr.DeclareSubscriber("/a/**", func(s *yaks.Sample) { samples <- s })
+
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.WriteDataWO("/a/b", nil, yaks.STRING, yaks.PUT); err != errClosed {
+		t.Errorf("WriteDataWO after Close: %v, want errClosed", err)
+	}
+	if err := tr.Query("/a/b", "", func(*yaks.Reply) {}); err != errClosed {
+		t.Errorf("Query after Close: %v, want errClosed", err)
+	}
+	if err := tr.(yaks.Pinger).Ping(time.Second); err != errClosed {
+		t.Errorf("Ping after Close: %v, want errClosed", err)
+	}
+	// the subscribers of the closed session are undeclared
+	other.WriteDataWO("/a/b", []byte("1"), yaks.STRING, yaks.PUT)
+	select {
+	case s := <-samples:
+		t.Errorf("sample received by a closed session: %+v", s)
+	default:
+	}
+}
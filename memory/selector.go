@@ -0,0 +1,43 @@
+package memory
+
+import "strings"
+
+// Intersect returns true if the 2 path expressions a and b intersect (i.e. some path matches both).
+// In a path expression, '*' matches any sequence of characters except '/', and a "**" chunk
+// matches any sequence of chunks (possibly empty). A path (without wildcard) matches
+// an expression if both intersect.
+func Intersect(a string, b string) bool {
+	return intersectChunks(strings.Split(a, "/"), strings.Split(b, "/"))
+}
+
+func intersectChunks(a []string, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	if len(a) > 0 && a[0] == "**" {
+		return intersectChunks(a[1:], b) || (len(b) > 0 && intersectChunks(a, b[1:]))
+	}
+	if len(b) > 0 && b[0] == "**" {
+		return intersectChunks(a, b[1:]) || (len(a) > 0 && intersectChunks(a[1:], b))
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	return intersectChunk(a[0], b[0]) && intersectChunks(a[1:], b[1:])
+}
+
+func intersectChunk(a string, b string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	if len(a) > 0 && a[0] == '*' {
+		return intersectChunk(a[1:], b) || (len(b) > 0 && intersectChunk(a, b[1:]))
+	}
+	if len(b) > 0 && b[0] == '*' {
+		return intersectChunk(a, b[1:]) || (len(a) > 0 && intersectChunk(a[1:], b))
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	return a[0] == b[0] && intersectChunk(a[1:], b[1:])
+}
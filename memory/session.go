@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithFields(log.Fields{" pkg": "yaks/memory"})
+
+// errClosed is returned by the operations on a closed session
+var errClosed = errors.New("session closed")
+
+// session is a session with an Engine, implementing yaks.Transport
+type session struct {
+	e      *Engine
+	id     [16]byte
+	mu     sync.Mutex
+	closed bool
+}
+
+func (s *session) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *session) WriteDataWO(path string, payload []byte, encoding yaks.Encoding, kind yaks.ChangeKind) error {
+	if s.isClosed() {
+		return errClosed
+	}
+	s.e.write("", []yaks.Sample{{Path: path, Data: payload, Encoding: encoding, Kind: kind}})
+	return nil
+}
+
+func (s *session) WriteBatch(batchID string, samples []yaks.Sample) error {
+	if s.isClosed() {
+		return errClosed
+	}
+	// copy the samples, as the engine sets their Timestamp and BatchID
+	s.e.write(batchID, append([]yaks.Sample(nil), samples...))
+	return nil
+}
+
+func (s *session) Query(path string, predicate string, handler yaks.ReplyHandler) error {
+	if s.isClosed() {
+		return errClosed
+	}
+	go s.e.query(path, predicate, handler)
+	return nil
+}
+
+func (s *session) DeclareSubscriber(path string, handler yaks.SampleHandler) (yaks.TransportSubscriber, error) {
+	if s.isClosed() {
+		return nil, errClosed
+	}
+	sub := &subscriber{s, path, handler}
+	s.e.mu.Lock()
+	s.e.subs[sub] = struct{}{}
+	s.e.mu.Unlock()
+	return sub, nil
+}
+
+func (s *session) UndeclareSubscriber(sub yaks.TransportSubscriber) error {
+	msub, ok := sub.(*subscriber)
+	if !ok || msub.s != s {
+		return errors.New("unknown subscriber")
+	}
+	s.e.mu.Lock()
+	delete(s.e.subs, msub)
+	s.e.mu.Unlock()
+	return nil
+}
+
+func (s *session) DeclareEval(path string, handler yaks.QueryHandler) (yaks.TransportEval, error) {
+	if s.isClosed() {
+		return nil, errClosed
+	}
+	ev := &eval{s, path, handler}
+	s.e.mu.Lock()
+	s.e.evals[ev] = struct{}{}
+	s.e.mu.Unlock()
+	return ev, nil
+}
+
+func (s *session) UndeclareEval(ev yaks.TransportEval) error {
+	mev, ok := ev.(*eval)
+	if !ok || mev.s != s {
+		return errors.New("unknown eval")
+	}
+	s.e.mu.Lock()
+	delete(s.e.evals, mev)
+	s.e.mu.Unlock()
+	return nil
+}
+
+// Ping returns an error if the session is closed
+func (s *session) Ping(timeout time.Duration) error {
+	if s.isClosed() {
+		return errClosed
+	}
+	return nil
+}
+
+// MergesUpdates returns true: the storages of the Engine merge the updates
+func (s *session) MergesUpdates() bool {
+	return true
+}
+
+func (s *session) Info() *yaks.SessionInfo {
+	return &yaks.SessionInfo{
+		SessionID: hex.EncodeToString(s.id[:]),
+		YaksID:    s.e.yaksid,
+		Locator:   Locator,
+	}
+}
+
+// Close terminates the session, undeclaring all its subscribers and evals.
+func (s *session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errClosed
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	s.e.mu.Lock()
+	defer s.e.mu.Unlock()
+	for sub := range s.e.subs {
+		if sub.s == s {
+			delete(s.e.subs, sub)
+		}
+	}
+	for ev := range s.e.evals {
+		if ev.s == s {
+			delete(s.e.evals, ev)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package yaks
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Merge returns the result of an update of the value base with the value delta, according to their encoding:
+//   - PROPERTIES: the properties of delta are added to the properties of base (replacing the existing keys)
+//   - JSON: delta is applied to base as a JSON merge patch (RFC 7386)
+//   - other encodings: the result is delta (i.e. as a put)
+//
+// If base is nil, delta is merged with an empty value. If base and delta have different
+// encodings, the result is delta.
+func Merge(base Value, delta Value) (Value, error) {
+	if base != nil && base.Encoding() != delta.Encoding() {
+		return delta, nil
+	}
+	if delta.Encoding() == JSON {
+		return mergeJSON(base, delta)
+	}
+	switch d := delta.(type) {
+	case *PropertiesValue:
+		p := make(Properties)
+		if b, ok := base.(*PropertiesValue); ok {
+			for k, v := range b.p {
+				p[k] = v
+			}
+		}
+		for k, v := range d.p {
+			p[k] = v
+		}
+		return NewPropertiesValue(p), nil
+
+	default:
+		return delta, nil
+	}
+}
+
+// mergeJSON applies the JSON value delta to the JSON value base (possibly nil) as a JSON merge patch
+func mergeJSON(base Value, delta Value) (Value, error) {
+	var target interface{}
+	if base != nil {
+		if err := unmarshalJSON(base.Encode(), &target); err != nil {
+			return nil, &YError{msg: "invalid JSON value to be updated", kind: ErrDecode, cause: err}
+		}
+	}
+	var patch interface{}
+	if err := unmarshalJSON(delta.Encode(), &patch); err != nil {
+		return nil, &YError{msg: "invalid JSON merge patch", kind: ErrDecode, cause: err}
+	}
+	result, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return nil, &YError{msg: "failed to encode merged JSON value", cause: err}
+	}
+	return NewJSONValue(string(result)), nil
+}
+
+// unmarshalJSON decodes data, keeping the numbers as is
+func unmarshalJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// mergePatch applies a JSON merge patch to target, as specified by RFC 7386
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	result := make(map[string]interface{})
+	if t, ok := target.(map[string]interface{}); ok {
+		for k, v := range t {
+			result[k] = v
+		}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(result, k)
+		} else {
+			result[k] = mergePatch(result[k], v)
+		}
+	}
+	return result
+}
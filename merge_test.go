@@ -0,0 +1,146 @@
+package yaks
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergePatch(t *testing.T) {
+	// examples of RFC 7386, Appendix A
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// nested objects are merged recursively
+		{`{"a":{"b":{"c":1,"d":2}},"e":3}`, `{"a":{"b":{"d":null,"f":4}}}`, `{"a":{"b":{"c":1,"f":4}},"e":3}`},
+		// the numbers are kept as is
+		{`{"n":12345678901234567890}`, `{"m":1.50}`, `{"m":1.50,"n":12345678901234567890}`},
+	}
+	for _, tt := range tests {
+		var target, patch interface{}
+		if err := unmarshalJSON([]byte(tt.target), &target); err != nil {
+			t.Fatal(err)
+		}
+		if err := unmarshalJSON([]byte(tt.patch), &patch); err != nil {
+			t.Fatal(err)
+		}
+		got, err := json.Marshal(mergePatch(target, patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("mergePatch(%s, %s) = %s, want %s", tt.target, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name        string
+		base, delta Value
+		want        Value
+	}{
+		{"properties", NewPropertiesValue(Properties{"a": "1", "b": "2"}), NewPropertiesValue(Properties{"b": "3", "c": "4"}),
+			NewPropertiesValue(Properties{"a": "1", "b": "3", "c": "4"})},
+		{"properties without base", nil, NewPropertiesValue(Properties{"a": "1"}), NewPropertiesValue(Properties{"a": "1"})},
+		{"json", NewJSONValue(`{"a":1,"b":{"c":2}}`), NewJSONValue(`{"b":{"c":null,"d":3}}`), NewJSONValue(`{"a":1,"b":{"d":3}}`)},
+		{"json without base", nil, NewJSONValue(`{"a":1,"b":null}`), NewJSONValue(`{"a":1}`)},
+		{"string", NewStringValue("a"), NewStringValue("b"), NewStringValue("b")},
+		{"different encodings", NewStringValue("a"), NewJSONValue(`{"a":1}`), NewJSONValue(`{"a":1}`)},
+	}
+	for _, tt := range tests {
+		got, err := Merge(tt.base, tt.delta)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Merge = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeInvalidJSON(t *testing.T) {
+	if _, err := Merge(NewJSONValue("{"), NewJSONValue(`{"a":1}`)); err == nil {
+		t.Error("merge with an invalid base succeeded")
+	}
+	if _, err := Merge(nil, NewJSONValue("{")); err == nil {
+		t.Error("merge of an invalid patch succeeded")
+	}
+}
+
+func TestJSONDecodesToStringValue(t *testing.T) {
+	v, err := DecodeValue(JSON, []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := v.(*StringValue)
+	if !ok {
+		t.Fatalf("JSON decoded to %T, want *StringValue", v)
+	}
+	if s.Encoding() != JSON || s.ToString() != `{"a":1}` {
+		t.Errorf("decoded JSON = %q with encoding %d", s.ToString(), s.Encoding())
+	}
+}
+
+func TestMergeCache(t *testing.T) {
+	path, _ := NewPath("/a")
+	ts := func(sec int64) Timestamp {
+		return NewTimestamp(time.Unix(sec, 0), [16]byte{1})
+	}
+	change := func(kind ChangeKind, v Value) *Change {
+		return &Change{path: path, kind: kind, value: v, delta: v}
+	}
+	c := &mergeCache{values: make(map[Path]*mergedValue)}
+
+	// an update received before the initialization is merged with the value got afterwards
+	t1 := ts(1)
+	if c.apply(change(UPDATE, NewJSONValue(`{"b":2}`)), &t1) {
+		t.Error("update applied before the initialization")
+	}
+	var delivered []Change
+	c.init([]Entry{{path: path, value: NewJSONValue(`{"a":1}`), tstamp: &Timestamp{}}}, func(changes []Change) {
+		delivered = changes
+	})
+	if len(delivered) != 1 || delivered[0].Value().ToString() != `{"a":1,"b":2}` {
+		t.Fatalf("delivered after init: %v", delivered)
+	}
+
+	// an older change is ignored
+	t0 := ts(0)
+	if c.apply(change(PUT, NewJSONValue(`{}`)), &t0) {
+		t.Error("older change applied")
+	}
+
+	// a tombstone ignores the older changes until it's purged
+	t3 := ts(3)
+	if !c.apply(change(REMOVE, nil), &t3) {
+		t.Fatal("remove ignored")
+	}
+	t2 := ts(2)
+	if c.apply(change(PUT, NewJSONValue(`{}`)), &t2) {
+		t.Error("change older than the removal applied")
+	}
+	c.values[*path].removed = time.Now().Add(-mergeTombstoneRetention)
+	c.lastPurge = time.Time{}
+	c.purgeTombstones()
+	if _, ok := c.values[*path]; ok {
+		t.Error("tombstone not purged after its retention")
+	}
+}
@@ -0,0 +1,130 @@
+package yaks
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"sort"
+)
+
+// Order is the order of the entries returned by Workspace.GetWithOptions and Workspace.GetPage
+type Order uint8
+
+const (
+	// OrderByPath sorts the entries by path, then by timestamp
+	OrderByPath Order = iota
+	// OrderByTimestamp sorts the entries by timestamp, then by path
+	OrderByTimestamp
+)
+
+// less returns true if the entry a is before the entry b in the Order o
+func (o Order) less(a *Entry, b *Entry) bool {
+	if o == OrderByTimestamp && *a.tstamp != *b.tstamp {
+		return a.tstamp.Before(b.tstamp)
+	}
+	if a.path.path != b.path.path {
+		return a.path.path < b.path.path
+	}
+	if *a.tstamp != *b.tstamp {
+		return a.tstamp.Before(b.tstamp)
+	}
+	return a.sourceID < b.sourceID
+}
+
+// sortEntries sorts the entries in the Order o (keeping the order of the duplicates from a same source)
+func sortEntries(results []Entry, o Order) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return o.less(&results[i], &results[j])
+	})
+}
+
+// Page is a page of the result of a Get
+type Page struct {
+	// Entries are the entries of the page
+	Entries []Entry
+	// Cursor allows to get the next page, setting it in GetOptions.Cursor (empty if this page is the last one)
+	Cursor string
+}
+
+// GetPage gets a page of a selection of path/value from Yaks, as GetWithOptions does.
+// To page through a large result, set a Limit, and then the Cursor of each Page in the options
+// of the next call. As the Cursor refers to the last Entry of the Page (and not to its index),
+// it remains valid even if entries are added or removed between the calls.
+// options can be nil (i.e. the default options: the whole result in a single Page).
+func (w *Workspace) GetPage(selector *Selector, options *GetOptions) (*Page, error) {
+	return w.GetPageContext(context.Background(), selector, options)
+}
+
+// GetPageContext is the same as GetPage, with a context as for GetContext.
+func (w *Workspace) GetPageContext(ctx context.Context, selector *Selector, options *GetOptions) (*Page, error) {
+	if options == nil {
+		options = new(GetOptions)
+	}
+	var after *Entry
+	if len(options.Cursor) > 0 {
+		var err error
+		if after, err = decodeCursor(options.Cursor, options.Order); err != nil {
+			return nil, &YError{Op: "Get", Path: selector.ToString(), msg: "invalid cursor", cause: err}
+		}
+	}
+	results, err := w.getWithOptions(ctx, selector, options)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil {
+		i := sort.Search(len(results), func(i int) bool {
+			return options.Order.less(after, &results[i])
+		})
+		results = results[i:]
+	}
+	if options.Offset > 0 {
+		if options.Offset >= len(results) {
+			results = results[len(results):]
+		} else {
+			results = results[options.Offset:]
+		}
+	}
+	page := &Page{Entries: results}
+	if options.Limit > 0 && len(results) > options.Limit {
+		page.Entries = results[:options.Limit]
+		page.Cursor = encodeCursor(&page.Entries[options.Limit-1], options.Order)
+	}
+	return page, nil
+}
+
+// encodeCursor returns a cursor referring to the entry e, in the Order o.
+// It's made of the order (1 byte), the time (8 bytes) and the clock id (16 bytes) of the entry's timestamp,
+// the length of the entry's path (as a varint), the path and the entry's source id, encoded in URL-compatible base64.
+func encodeCursor(e *Entry, o Order) string {
+	buf := make([]byte, 25+binary.MaxVarintLen64+len(e.path.path)+len(e.sourceID))
+	buf[0] = byte(o)
+	binary.BigEndian.PutUint64(buf[1:], e.tstamp.time)
+	copy(buf[9:], e.tstamp.clockID[:])
+	n := 25 + binary.PutUvarint(buf[25:], uint64(len(e.path.path)))
+	n += copy(buf[n:], e.path.path)
+	n += copy(buf[n:], e.sourceID)
+	return base64.RawURLEncoding.EncodeToString(buf[:n])
+}
+
+// decodeCursor returns the entry (without value) a cursor refers to, checking it was made for the Order o
+func decodeCursor(cursor string, o Order) (*Entry, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 26 {
+		return nil, &YError{msg: "cursor too short"}
+	}
+	if Order(buf[0]) != o {
+		return nil, &YError{msg: "cursor not made for this order"}
+	}
+	ts := &Timestamp{time: binary.BigEndian.Uint64(buf[1:])}
+	copy(ts.clockID[:], buf[9:25])
+	l, n := binary.Uvarint(buf[25:])
+	if n <= 0 || uint64(len(buf)-25-n) < l {
+		return nil, &YError{msg: "invalid path length in cursor"}
+	}
+	start := 25 + n
+	end := start + int(l)
+	return &Entry{path: &Path{string(buf[start:end])}, tstamp: ts, sourceID: string(buf[end:])}, nil
+}
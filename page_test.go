@@ -0,0 +1,75 @@
+package yaks
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	path, _ := NewPath("/a/b/c")
+	ts := NewTimestamp(time.Unix(1600000000, 123456789), [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	for _, o := range []Order{OrderByPath, OrderByTimestamp} {
+		for _, sourceID := range []string{"", "storage-1"} {
+			e := &Entry{path: path, tstamp: &ts, sourceID: sourceID}
+			got, err := decodeCursor(encodeCursor(e, o), o)
+			if err != nil {
+				t.Fatalf("decodeCursor(order %d, source %q): %v", o, sourceID, err)
+			}
+			if *got.path != *path || *got.tstamp != ts || got.sourceID != sourceID {
+				t.Errorf("decodeCursor(order %d) = {%v %v %q}, want {%v %v %q}",
+					o, got.path, got.tstamp, got.sourceID, path, &ts, sourceID)
+			}
+		}
+	}
+}
+
+func TestCursorInvalid(t *testing.T) {
+	path, _ := NewPath("/a/b")
+	ts := NewTimestamp(time.Unix(1600000000, 0), [16]byte{1})
+	cursor := encodeCursor(&Entry{path: path, tstamp: &ts, sourceID: "s"}, OrderByPath)
+	buf, _ := base64.RawURLEncoding.DecodeString(cursor)
+	encode := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	// a path length greater than the remaining bytes
+	tooLong := append([]byte(nil), buf...)
+	tooLong[25] = 100
+
+	tests := []struct {
+		name   string
+		cursor string
+		order  Order
+	}{
+		{"other order", cursor, OrderByTimestamp},
+		{"not base64", "!" + cursor, OrderByPath},
+		{"truncated", encode(buf[:20]), OrderByPath},
+		{"empty path length", encode(buf[:25]), OrderByPath},
+		{"path length too long", encode(tooLong), OrderByPath},
+		{"invalid varint", encode(append(append([]byte(nil), buf[:25]...), 0xff, 0xff)), OrderByPath},
+	}
+	for _, tt := range tests {
+		if e, err := decodeCursor(tt.cursor, tt.order); err == nil {
+			t.Errorf("%s: decodeCursor succeeded with %+v", tt.name, e)
+		}
+	}
+}
+
+func TestOrderLess(t *testing.T) {
+	a, _ := NewPath("/a")
+	b, _ := NewPath("/b")
+	t1 := NewTimestamp(time.Unix(1, 0), [16]byte{})
+	t2 := NewTimestamp(time.Unix(2, 0), [16]byte{})
+	a2 := &Entry{path: a, tstamp: &t2}
+	b1 := &Entry{path: b, tstamp: &t1}
+	if !OrderByPath.less(a2, b1) || OrderByPath.less(b1, a2) {
+		t.Error("OrderByPath doesn't sort by path first")
+	}
+	if !OrderByTimestamp.less(b1, a2) || OrderByTimestamp.less(a2, b1) {
+		t.Error("OrderByTimestamp doesn't sort by timestamp first")
+	}
+	a2s := &Entry{path: a, tstamp: &t2, sourceID: "s"}
+	if !OrderByPath.less(a2, a2s) || OrderByPath.less(a2s, a2) {
+		t.Error("the entries of a same path and timestamp are not sorted by source")
+	}
+}
@@ -0,0 +1,27 @@
+package yaks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+func TestPutIf(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", nil)
+	w := s.Workspace("/")
+	if _, err := w.PutIf(path(t, "/a/x"), yaks.NewStringValue("1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	current, err := w.PutIf(path(t, "/a/x"), yaks.NewStringValue("2"), nil)
+	if !errors.Is(err, yaks.ErrConflict) || current == nil || current.Value().ToString() != "1" {
+		t.Fatalf("PutIf on an existing path = %v, %v; want a conflict with the current entry", current, err)
+	}
+	if _, err := w.PutIf(path(t, "/a/x"), yaks.NewStringValue("2"), current.Timestamp()); err != nil {
+		t.Errorf("PutIf with the current timestamp: %v", err)
+	}
+	s.AssertPut("/a/x", yaks.NewStringValue("2"))
+}
@@ -0,0 +1,106 @@
+package yaks
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+)
+
+// Quorum defines when a Get with Workspace.GetQuorum returns, without waiting for all the replies.
+// The quorum is reached once Sources sources have replied, and for each path received so far,
+// the latest entries from Sources sources agree (i.e. have the same timestamp and value).
+// If a path can't reach the quorum, the Get waits for all the replies (or until the Deadline).
+type Quorum struct {
+	// Sources is the number of sources (storages or evals) that must agree (0 means all the sources).
+	Sources int
+	// Deadline is the maximum duration to wait for the replies (0 means no deadline).
+	// When it expires, the entries received so far are returned.
+	Deadline time.Duration
+}
+
+// reached returns true if the quorum is reached, with the replies of the given number of sources
+func (q *Quorum) reached(sources int, qresults map[Path]entries) bool {
+	if q.Sources <= 0 || sources < q.Sources {
+		return false
+	}
+	for _, entries := range qresults {
+		if entries.latestPerSource().agreeing() < q.Sources {
+			return false
+		}
+	}
+	return true
+}
+
+// QuorumFirst returns a Quorum waiting for the first source only.
+func QuorumFirst() Quorum {
+	return Quorum{Sources: 1}
+}
+
+// QuorumN returns a Quorum waiting for n sources to agree.
+func QuorumN(n int) Quorum {
+	return Quorum{Sources: n}
+}
+
+// QuorumAllBefore returns a Quorum waiting for all the sources until the deadline expires.
+func QuorumAllBefore(deadline time.Duration) Quorum {
+	return Quorum{Deadline: deadline}
+}
+
+// GetQuorum gets a selection of path/value from Yaks, as GetWithReport does, but returns as soon as
+// the quorum is reached (GetReport.Partial is then true if some replies were still outstanding).
+// The latest entry of each path is returned, and the paths for which the sources replied with
+// different entries are reported in GetReport.Disagreements.
+func (w *Workspace) GetQuorum(selector *Selector, quorum Quorum) ([]Entry, *GetReport, error) {
+	return w.GetQuorumContext(context.Background(), selector, quorum)
+}
+
+// GetQuorumContext is the same as GetQuorum, with a context as for GetContext.
+func (w *Workspace) GetQuorumContext(ctx context.Context, selector *Selector, quorum Quorum) ([]Entry, *GetReport, error) {
+	qresults, report, err := w.query(ctx, selector, &quorum)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Disagreements = disagreements(qresults)
+	return consolidate(selector, qresults, ConsolidationLatest), report, nil
+}
+
+// sameEntry returns true if the entries a and b have the same timestamp and value
+func sameEntry(a *Entry, b *Entry) bool {
+	return *a.tstamp == *b.tstamp && a.value.Encoding() == b.value.Encoding() &&
+		bytes.Equal(a.value.Encode(), b.value.Encode())
+}
+
+// agreeing returns the size of the largest group of same entries (see sameEntry)
+func (e entries) agreeing() int {
+	max := 0
+	for i := range e {
+		n := 0
+		for j := range e {
+			if sameEntry(&e[i], &e[j]) {
+				n++
+			}
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// disagreements returns the paths for which the latest entries from each source are not all the same
+func disagreements(qresults map[Path]entries) []Disagreement {
+	var result []Disagreement
+	for path, entries := range qresults {
+		latest := entries.latestPerSource()
+		if latest.agreeing() < len(latest) {
+			p := path
+			sortEntries(latest, OrderByTimestamp)
+			result = append(result, Disagreement{&p, latest})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path.path < result[j].Path.path
+	})
+	return result
+}
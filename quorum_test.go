@@ -0,0 +1,33 @@
+package yaks_test
+
+import (
+	"testing"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+func TestGetQuorum(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st1", "/a/**", map[string]yaks.Value{"/a/x": yaks.NewStringValue("1")})
+	s.SetStorage("st2", "/a/**", nil)
+	// st2 doesn't have /a/x yet: st1 and st2 disagree on it
+	w := s.Workspace("/")
+	entries, report, err := w.GetQuorum(selector(t, "/a/*"), yaks.QuorumN(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || report.StorageSources != 2 || report.Partial {
+		t.Errorf("GetQuorum = %v, %+v; want /a/x from all the replies", entries, report)
+	}
+
+	w.Put(path(t, "/a/x"), yaks.NewStringValue("2"))
+	entries, report, err = w.GetQuorum(selector(t, "/a/*"), yaks.QuorumN(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Value().ToString() != "2" || len(report.Disagreements) != 0 {
+		t.Errorf("GetQuorum = %v, %+v; want the agreed /a/x", entries, report)
+	}
+}
@@ -0,0 +1,247 @@
+package yaks_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/memory"
+)
+
+// lossyTransport is a session with a memory.Engine that can be blocked while declaring subscribers
+type lossyTransport struct {
+	yaks.Transport
+	// if not nil, DeclareSubscriber waits until it's closed
+	declaring chan struct{}
+}
+
+func (t *lossyTransport) DeclareSubscriber(path string, handler yaks.SampleHandler) (yaks.TransportSubscriber, error) {
+	if t.declaring != nil {
+		<-t.declaring
+	}
+	return t.Transport.DeclareSubscriber(path, handler)
+}
+
+func (t *lossyTransport) Ping(timeout time.Duration) error {
+	return t.Transport.(yaks.Pinger).Ping(timeout)
+}
+
+// lossyYaks is a Yaks reconnecting to a memory.Engine, whose sessions can be lost (i.e. closed)
+type lossyYaks struct {
+	y          *yaks.Yaks
+	e          *memory.Engine
+	mu         sync.Mutex
+	transports []*lossyTransport
+	// if not nil, the next sessions block in DeclareSubscriber until it's closed
+	declaring chan struct{}
+	states    chan yaks.State
+}
+
+func newLossyYaks(t *testing.T) *lossyYaks {
+	t.Helper()
+	l := &lossyYaks{e: memory.NewEngine(), states: make(chan yaks.State, 10)}
+	dial := func(locator *string, properties yaks.Properties) (yaks.Transport, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		tr := &lossyTransport{l.e.NewTransport(), l.declaring}
+		l.transports = append(l.transports, tr)
+		return tr, nil
+	}
+	y, err := yaks.LoginWithDialer(dial, &yaks.LoginOptions{
+		Locators:  []string{memory.Locator},
+		Retry:     yaks.RetryPolicy{MinDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		KeepAlive: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	y.OnStateChange(func(old yaks.State, new yaks.State) { l.states <- new })
+	l.y = y
+	return l
+}
+
+// lose closes the current session, which is detected by the keep-alive
+func (l *lossyYaks) lose() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.transports[len(l.transports)-1].Close()
+}
+
+func (l *lossyYaks) awaitState(t *testing.T, want yaks.State) {
+	t.Helper()
+	for {
+		select {
+		case s := <-l.states:
+			if s == want {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("state %v not reached", want)
+		}
+	}
+}
+
+func TestReconnectRedeclares(t *testing.T) {
+	l := newLossyYaks(t)
+	defer l.y.Logout()
+	w := l.y.Workspace(path(t, "/"))
+	c := newChanges()
+	if _, err := w.Subscribe(selector(t, "/a/*"), c.listener); err != nil {
+		t.Fatal(err)
+	}
+	err := w.RegisterEval(path(t, "/e"), func(*yaks.Path, yaks.Properties) yaks.Value {
+		return yaks.NewStringValue("e")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.lose()
+	l.awaitState(t, yaks.StateReconnecting)
+	l.awaitState(t, yaks.StateConnected)
+
+	other := l.e.NewTransport()
+	defer other.Close()
+	other.WriteDataWO("/a/x", []byte("1"), yaks.STRING, yaks.PUT)
+	if received := c.await(t, 1); received[0][0].Path().ToString() != "/a/x" {
+		t.Errorf("notification after reconnection = %+v", received[0])
+	}
+	entries, err := w.GetWithOptions(selector(t, "/e"), nil)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("eval after reconnection replied %v, %v", entries, err)
+	}
+}
+
+func TestUnsubscribeWhileRedeclaring(t *testing.T) {
+	l := newLossyYaks(t)
+	defer l.y.Logout()
+	w := l.y.Workspace(path(t, "/"))
+	c := newChanges()
+	subid, err := w.Subscribe(selector(t, "/a/*"), c.listener)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declaring := make(chan struct{})
+	l.mu.Lock()
+	l.declaring = declaring
+	l.mu.Unlock()
+	l.lose()
+	l.awaitState(t, yaks.StateReconnecting)
+	unsubscribed := make(chan error, 1)
+	// wait for the re-declaration to be blocked, then unsubscribe meanwhile
+	time.Sleep(50 * time.Millisecond)
+	go func() { unsubscribed <- w.Unsubscribe(subid) }()
+	time.Sleep(20 * time.Millisecond)
+	close(declaring)
+	if err := <-unsubscribed; err != nil {
+		t.Fatal(err)
+	}
+	l.awaitState(t, yaks.StateConnected)
+
+	other := l.e.NewTransport()
+	defer other.Close()
+	other.WriteDataWO("/a/x", []byte("1"), yaks.STRING, yaks.PUT)
+	select {
+	case <-c.notified:
+		t.Error("change notified to a subscriber removed during its re-declaration")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLostSessionFailsPendingGet(t *testing.T) {
+	l := newLossyYaks(t)
+	defer l.y.Logout()
+	// an eval that never replies keeps the Get pending
+	l.e.SetEvalTimeout(time.Minute)
+	block := make(chan struct{})
+	defer close(block)
+	router := l.e.NewTransport()
+	defer router.Close()
+	router.DeclareEval("/e", func(string, string, yaks.RepliesSender) { <-block })
+
+	w := l.y.Workspace(path(t, "/"))
+	result := make(chan error, 1)
+	go func() {
+		_, err := w.GetWithOptions(selector(t, "/e"), nil)
+		result <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	l.lose()
+	select {
+	case err := <-result:
+		if !errors.Is(err, yaks.ErrDisconnected) {
+			t.Errorf("pending Get failed with %v, want ErrDisconnected", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending Get not failed by the loss of the session")
+	}
+
+	l.awaitState(t, yaks.StateConnected)
+	if err := l.y.Logout(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.GetWithOptions(selector(t, "/e"), nil); !errors.Is(err, yaks.ErrClosed) {
+		t.Errorf("Get after Logout: %v, want ErrClosed", err)
+	}
+	if info := l.y.Info(); info.YaksID != l.e.ID() {
+		t.Errorf("Info after Logout = %+v", info)
+	}
+}
+
+func TestNotConnectedErrorIsDisconnected(t *testing.T) {
+	var err error = &yaks.NotConnectedError{State: yaks.StateReconnecting}
+	if !errors.Is(err, yaks.ErrDisconnected) {
+		t.Error("NotConnectedError doesn't match ErrDisconnected")
+	}
+}
+
+func TestTransportFailureWithoutDialer(t *testing.T) {
+	e := memory.NewEngine()
+	tr := e.NewTransport()
+	y, err := yaks.NewWithTransport(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Logout()
+	states := make(chan yaks.State, 10)
+	y.OnStateChange(func(old yaks.State, new yaks.State) { states <- new })
+	lost := make(chan error, 1)
+	y.SetConnectionListener(func(connected bool, err error) {
+		if !connected {
+			lost <- err
+		}
+	})
+	w := y.Workspace(path(t, "/"))
+
+	tr.Close()
+	if err := w.Put(path(t, "/a/x"), yaks.NewStringValue("1")); err == nil {
+		t.Fatal("Put on a failed Transport succeeded")
+	}
+	if s := y.State(); s != yaks.StateDisconnected {
+		t.Errorf("state after the Transport failure = %v, want disconnected", s)
+	}
+	if s := <-states; s != yaks.StateDisconnected {
+		t.Errorf("state notified = %v, want disconnected", s)
+	}
+	if err := <-lost; err == nil {
+		t.Error("loss notified without its cause")
+	}
+	err = w.Put(path(t, "/a/x"), yaks.NewStringValue("1"))
+	var nce *yaks.NotConnectedError
+	if !errors.Is(err, yaks.ErrDisconnected) || !errors.As(err, &nce) || nce.State != yaks.StateDisconnected {
+		t.Errorf("Put after the Transport failure: %v, want a NotConnectedError", err)
+	}
+	var yerr *yaks.YError
+	if !errors.As(err, &yerr) || yerr.Op != "Put" || yerr.Path != "/a/x" {
+		t.Errorf("Put after the Transport failure: %#v, want a YError on Put /a/x", err)
+	}
+	if err := y.Logout(); err != nil {
+		t.Error(err)
+	}
+	if s := y.State(); s != yaks.StateClosed {
+		t.Errorf("state after Logout = %v, want closed", s)
+	}
+}
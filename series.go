@@ -0,0 +1,150 @@
+package yaks
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Properties of a Selector defining a time range
+const (
+	PropStartTime = "starttime"
+	PropStopTime  = "stoptime"
+)
+
+// SelectorBuilder builds a Selector, setting its properties (e.g. the time range for a time series).
+type SelectorBuilder struct {
+	path      string
+	predicate string
+	fragment  string
+	keys      []string
+	values    map[string]string
+}
+
+// NewSelectorBuilder returns a new SelectorBuilder starting from selector (keeping its properties).
+func NewSelectorBuilder(selector *Selector) *SelectorBuilder {
+	b := &SelectorBuilder{
+		path:      selector.Path(),
+		predicate: selector.Predicate(),
+		fragment:  selector.Fragment(),
+		values:    make(map[string]string),
+	}
+	if len(selector.Properties()) > 0 {
+		for _, kv := range strings.Split(selector.Properties(), propSep) {
+			if i := strings.Index(kv, kvSep); i < 0 {
+				b.Property(kv, "")
+			} else {
+				b.Property(kv[:i], kv[i+1:])
+			}
+		}
+	}
+	return b
+}
+
+// Property sets a property of the Selector (replacing the previous value of key, if any).
+func (b *SelectorBuilder) Property(key string, value string) *SelectorBuilder {
+	if _, ok := b.values[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.values[key] = value
+	return b
+}
+
+// StartTime sets the start time of the time range (omitted if t is zero).
+func (b *SelectorBuilder) StartTime(t time.Time) *SelectorBuilder {
+	if t.IsZero() {
+		return b
+	}
+	return b.Property(PropStartTime, formatTime(t))
+}
+
+// StopTime sets the stop time of the time range (omitted if t is zero).
+func (b *SelectorBuilder) StopTime(t time.Time) *SelectorBuilder {
+	if t.IsZero() {
+		return b
+	}
+	return b.Property(PropStopTime, formatTime(t))
+}
+
+// StartTimeFromNow sets the start time of the time range relatively to the time of the query
+// (e.g. -1h gives "now()-1h").
+func (b *SelectorBuilder) StartTimeFromNow(d time.Duration) *SelectorBuilder {
+	return b.Property(PropStartTime, formatFromNow(d))
+}
+
+// StopTimeFromNow sets the stop time of the time range relatively to the time of the query
+// (e.g. 0 gives "now()").
+func (b *SelectorBuilder) StopTimeFromNow(d time.Duration) *SelectorBuilder {
+	return b.Property(PropStopTime, formatFromNow(d))
+}
+
+// Build returns the Selector, or an error if it's invalid.
+func (b *SelectorBuilder) Build() (*Selector, error) {
+	props := make([]string, len(b.keys))
+	for i, k := range b.keys {
+		props[i] = k + kvSep + b.values[k]
+	}
+	s := newSelector(b.path, b.predicate, strings.Join(props, propSep), b.fragment)
+	return NewSelector(s.ToString())
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatFromNow(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "now()"
+	case d < 0:
+		return "now()-" + formatDuration(-d)
+	default:
+		return "now()+" + formatDuration(d)
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	default:
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+	}
+}
+
+// GetSeries gets a selection of path/value from Yaks, as a time series per Path:
+// all the values of each path, sorted per Timestamp (typically with a selector having a time range).
+func (w *Workspace) GetSeries(selector *Selector) (map[Path][]Entry, error) {
+	return w.GetSeriesContext(context.Background(), selector)
+}
+
+// GetSeriesContext is the same as GetSeries, with a context as for GetContext.
+func (w *Workspace) GetSeriesContext(ctx context.Context, selector *Selector) (map[Path][]Entry, error) {
+	qresults, _, err := w.query(ctx, selector, nil)
+	if err != nil {
+		return nil, err
+	}
+	series := make(map[Path][]Entry, len(qresults))
+	for path, entries := range qresults {
+		series[path] = entries.asSortedSet()
+	}
+	return series, nil
+}
+
+// GetRange gets the time series of the path/values matching selector between from and to
+// (a zero time meaning no bound), as GetSeries does.
+func (w *Workspace) GetRange(selector *Selector, from time.Time, to time.Time) (map[Path][]Entry, error) {
+	return w.GetRangeContext(context.Background(), selector, from, to)
+}
+
+// GetRangeContext is the same as GetRange, with a context as for GetContext.
+func (w *Workspace) GetRangeContext(ctx context.Context, selector *Selector, from time.Time, to time.Time) (map[Path][]Entry, error) {
+	s, err := NewSelectorBuilder(selector).StartTime(from).StopTime(to).Build()
+	if err != nil {
+		return nil, &YError{Op: "GetRange", Path: selector.ToString(), kind: ErrInvalidSelector, cause: err}
+	}
+	return w.GetSeriesContext(ctx, s)
+}
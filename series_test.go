@@ -0,0 +1,75 @@
+package yaks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	for _, tt := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{time.Hour, "1h"},
+		{48 * time.Hour, "48h"},
+		{90 * time.Minute, "90m"},
+		{time.Minute, "1m"},
+		{30 * time.Second, "30s"},
+		{1500 * time.Millisecond, "1.5s"},
+		{time.Nanosecond, "0.000000001s"},
+	} {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestFormatFromNow(t *testing.T) {
+	for _, tt := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "now()"},
+		{-time.Hour, "now()-1h"},
+		{10 * time.Minute, "now()+10m"},
+		{-2500 * time.Millisecond, "now()-2.5s"},
+	} {
+		if got := formatFromNow(tt.d); got != tt.want {
+			t.Errorf("formatFromNow(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorBuilder(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.FixedZone("CET", 3600))
+	for _, tt := range []struct {
+		name     string
+		selector string
+		build    func(b *SelectorBuilder) *SelectorBuilder
+		want     string
+	}{
+		{"from now", "/a/*", func(b *SelectorBuilder) *SelectorBuilder {
+			return b.StartTimeFromNow(-time.Hour).StopTimeFromNow(0)
+		}, "/a/*?(starttime=now()-1h;stoptime=now())"},
+		{"absolute times in UTC", "/a/*", func(b *SelectorBuilder) *SelectorBuilder {
+			return b.StartTime(start).StopTime(time.Time{})
+		}, "/a/*?(starttime=2020-01-02T02:04:05.6Z)"},
+		{"kept predicate, properties and fragment", "/a/*?x>1(k=v;starttime=0)#f", func(b *SelectorBuilder) *SelectorBuilder {
+			return b.StartTimeFromNow(-time.Minute)
+		}, "/a/*?x>1(k=v;starttime=now()-1m)#f"},
+		{"no property", "/a/*", func(b *SelectorBuilder) *SelectorBuilder {
+			return b
+		}, "/a/*"},
+	} {
+		s, err := NewSelector(tt.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := tt.build(NewSelectorBuilder(s)).Build()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if got.ToString() != tt.want {
+			t.Errorf("%s: Build() = %s, want %s", tt.name, got.ToString(), tt.want)
+		}
+	}
+}
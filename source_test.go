@@ -0,0 +1,56 @@
+package yaks_test
+
+import (
+	"encoding/hex"
+	"sort"
+	"testing"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+func TestEntrySources(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st1", "/a/**", map[string]yaks.Value{"/a/x": yaks.NewStringValue("1")})
+	s.SetStorage("st2", "/a/**", nil)
+	w := s.Workspace("/")
+	w.Put(path(t, "/a/y"), yaks.NewStringValue("2"))
+	// the eval replies with a newer value of /a/x
+	s.ScriptEval("/a/x", yaks.NewStringValue("3"))
+	st1 := hex.EncodeToString([]byte("st1"))
+	st2 := hex.EncodeToString([]byte("st2"))
+	eval := hex.EncodeToString([]byte("/a/x"))
+
+	describe := func(entries []yaks.Entry) []string {
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Path().ToString()+" "+e.Value().ToString()+" "+e.SourceKind().String()+" "+e.SourceID())
+		}
+		sort.Strings(got)
+		return got
+	}
+	for _, tt := range []struct {
+		selector      string
+		consolidation yaks.Consolidation
+		want          []string
+	}{
+		{"/a/x", yaks.ConsolidationLatest, []string{
+			"/a/x 3 eval " + eval,
+		}},
+		{"/a/*", yaks.ConsolidationBySource, []string{
+			"/a/x 1 storage " + st1,
+			"/a/x 3 eval " + eval,
+			"/a/y 2 storage " + st1,
+			"/a/y 2 storage " + st2,
+		}},
+	} {
+		entries, err := w.GetWithOptions(selector(t, tt.selector), &yaks.GetOptions{Consolidation: tt.consolidation})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := describe(entries); !equalStrings(got, tt.want) {
+			t.Errorf("entries of %s with consolidation %d = %v, want %v", tt.selector, tt.consolidation, got, tt.want)
+		}
+	}
+}
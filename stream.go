@@ -0,0 +1,189 @@
+package yaks
+
+import (
+	"context"
+	"sync"
+)
+
+// EntryStream delivers the Entries of a GetStream as soon as the replies arrive.
+// The replies are received by the subroutine of the Transport receiving all the data of the session
+// (e.g. the Zenoh receive loop), which is never blocked by the stream: the Entries not consumed yet are
+// queued by the stream, without limit. So, a slow consumer only delays its own stream, but the Entries
+// should be consumed promptly, or the stream closed, to not keep a large selection in memory.
+type EntryStream struct {
+	selector string
+	entries  chan Entry
+	stop     chan struct{}
+	stopOnce sync.Once
+	lost     <-chan struct{}
+	lostErr  func() error
+	done     chan struct{}
+	err      error
+	// signaled when an Entry is queued or the final reply is received
+	ready chan struct{}
+	mu    sync.Mutex
+	// Entries received, not consumed yet
+	queue []Entry
+	final bool
+	// true once the stream is terminated: the replies are ignored
+	terminated bool
+	// first reply that failed to be decoded
+	decodeErr error
+}
+
+// Entries returns the channel of the Entries. It's closed when all the replies have been received,
+// or when the stream is interrupted (see Err).
+func (s *EntryStream) Entries() <-chan Entry {
+	return s.entries
+}
+
+// Done returns a channel that is closed when the stream is terminated.
+func (s *EntryStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err waits for the termination of the stream and returns an error matching ctx.Err() (and ErrTimeout
+// if its deadline was exceeded) if it was interrupted because the context of GetStreamContext was done,
+// an error matching ErrDisconnected if it was interrupted by the loss of the session with Yaks,
+// an error matching ErrDecode (about the first one) if some replies were skipped because they couldn't be decoded,
+// nil otherwise (including if stopped by Close).
+func (s *EntryStream) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Close stops the stream: the Entries channel is closed and the replies arriving later are ignored.
+func (s *EntryStream) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// push queues an Entry, or the final reply if e is nil, without blocking
+func (s *EntryStream) push(e *Entry) {
+	s.mu.Lock()
+	if s.terminated {
+		s.mu.Unlock()
+		return
+	}
+	if e != nil {
+		s.queue = append(s.queue, *e)
+	} else {
+		s.final = true
+	}
+	s.mu.Unlock()
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the next queued Entry (ok is false if none), and whether the final reply has been received
+func (s *EntryStream) next() (e Entry, ok bool, final bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return Entry{}, false, s.final
+	}
+	e = s.queue[0]
+	s.queue[0] = Entry{}
+	s.queue = s.queue[1:]
+	return e, true, false
+}
+
+// run forwards the queued Entries to the consumer until the final reply, ctx being done or Close
+func (s *EntryStream) run(ctx context.Context) {
+	defer func() {
+		s.mu.Lock()
+		s.terminated = true
+		s.queue = nil
+		s.mu.Unlock()
+		close(s.entries)
+		close(s.done)
+	}()
+	for {
+		e, ok, final := s.next()
+		if final {
+			s.mu.Lock()
+			s.err = s.decodeErr
+			s.mu.Unlock()
+			return
+		}
+		var out chan<- Entry
+		var ready <-chan struct{}
+		if ok {
+			out = s.entries
+		} else {
+			ready = s.ready
+		}
+		select {
+		case out <- e:
+		case <-ready:
+		case <-ctx.Done():
+			s.err = contextError(ctx, "GetStream", s.selector)
+			return
+		case <-s.stop:
+			return
+		case <-s.lost:
+			s.err = s.lostErr()
+			return
+		}
+	}
+}
+
+// GetStream gets a selection of path/value from Yaks, delivering each Entry as soon as it's received.
+// Contrary to Get, the Entries are not consolidated: several Entries with the same path might be delivered
+// (e.g. from different storages), and the replies that can't be decoded are skipped (see EntryStream.Err).
+// See EntryStream about the consumption of the Entries.
+func (w *Workspace) GetStream(selector *Selector) (*EntryStream, error) {
+	return w.GetStreamContext(context.Background(), selector)
+}
+
+// GetStreamContext is the same as GetStream, but the stream is interrupted when ctx is done.
+func (w *Workspace) GetStreamContext(ctx context.Context, selector *Selector) (*EntryStream, error) {
+	s := w.toAbsoluteSelector(selector)
+	logger := logger.WithField("selector", s)
+	logger.Debug("GetStream")
+
+	if ctx.Err() != nil {
+		return nil, contextError(ctx, "GetStream", s.ToString())
+	}
+	stream := &EntryStream{
+		selector: s.ToString(),
+		entries:  make(chan Entry),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		ready:    make(chan struct{}, 1),
+	}
+
+	replyCb := func(reply *Reply) {
+		switch reply.Kind {
+		case ReplyStorageData, ReplyEvalData:
+			entry, err := entryOfReply(logger, reply)
+			if err != nil {
+				stream.mu.Lock()
+				if stream.decodeErr == nil {
+					stream.decodeErr = &YError{Op: "GetStream", Path: reply.Path, msg: "reply skipped", kind: ErrDecode, cause: err}
+				}
+				stream.mu.Unlock()
+				return
+			}
+			stream.push(&entry)
+		case ReplyFinal:
+			logger.Trace("GetStream => Z_REPLY_FINAL")
+			stream.push(nil)
+		}
+	}
+
+	t, lost, err := w.querySession("GetStream", s.ToString())
+	if err != nil {
+		return nil, err
+	}
+	stream.lost = lost
+	stream.lostErr = func() error { return w.yaks.lostError("GetStream", s.ToString()) }
+	go stream.run(ctx)
+	if err := t.Query(s.Path(), s.OptionalPart(), replyCb); err != nil {
+		stream.Close()
+		w.yaks.transportFailed(t, err)
+		return nil, &YError{Op: "GetStream", Path: s.ToString(), cause: err}
+	}
+	return stream, nil
+}
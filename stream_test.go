@@ -0,0 +1,94 @@
+package yaks_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+func TestGetStream(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", map[string]yaks.Value{
+		"/a/x": yaks.NewStringValue("1"),
+		"/a/u": unknownValue{},
+	})
+	w := s.Workspace("/")
+	stream, err := w.GetStream(selector(t, "/a/*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []yaks.Entry
+	for e := range stream.Entries() {
+		got = append(got, e)
+	}
+	if len(got) != 1 || got[0].Path().ToString() != "/a/x" {
+		t.Errorf("streamed entries = %v, want /a/x", got)
+	}
+	if err := stream.Err(); !errors.Is(err, yaks.ErrDecode) {
+		t.Errorf("stream error = %v, want ErrDecode about /a/u", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, err := w.GetStreamContext(ctx, selector(t, "/a/*")); !errors.Is(err, yaks.ErrTimeout) {
+		t.Errorf("GetStreamContext with an expired context: %v, want ErrTimeout", err)
+	}
+}
+
+// replyWatcher is a session with a memory.Engine signaling on replied when a query handler
+// returns from the final reply
+type replyWatcher struct {
+	yaks.Transport
+	replied chan struct{}
+}
+
+func (t *replyWatcher) Query(path string, predicate string, handler yaks.ReplyHandler) error {
+	return t.Transport.Query(path, predicate, func(reply *yaks.Reply) {
+		handler(reply)
+		if reply.Kind == yaks.ReplyFinal {
+			close(t.replied)
+		}
+	})
+}
+
+func TestGetStreamSlowConsumer(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	contents := make(map[string]yaks.Value)
+	for i := 0; i < 200; i++ {
+		contents[fmt.Sprintf("/a/%d", i)] = yaks.NewStringValue("1")
+	}
+	s.SetStorage("st", "/a/**", contents)
+	tr := &replyWatcher{s.Engine().NewTransport(), make(chan struct{})}
+	y, err := yaks.NewWithTransport(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Logout()
+	w := y.Workspace(path(t, "/"))
+	stream, err := w.GetStream(selector(t, "/a/*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all the replies are received, while no Entry is consumed
+	select {
+	case <-tr.replied:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reception of the replies blocked by the stream")
+	}
+	count := 0
+	for range stream.Entries() {
+		count++
+	}
+	if count != 200 || stream.Err() != nil {
+		t.Errorf("%d entries streamed (error %v), want 200", count, stream.Err())
+	}
+}
@@ -0,0 +1,107 @@
+package yaks
+
+import "time"
+
+// Transport is the interface to the transport that Yaks uses to exchange data (e.g. Zenoh, with the zenohtransport package).
+// Implementing it allows to use Workspace and Admin without Zenoh (e.g. in tests or with alternative runtimes),
+// via NewWithTransport or LoginWithDialer.
+type Transport interface {
+	// WriteDataWO writes a data with its encoding and change kind on the path.
+	WriteDataWO(path string, payload []byte, encoding Encoding, kind ChangeKind) error
+	// WriteBatch writes all the samples (their Path, Data, Encoding and Kind) at once, with the same timestamp if possible.
+	// The subscribers receive the samples with BatchID set to batchID, and BatchSize set to the number of samples
+	// of the batch they receive.
+	// A Transport unable to do so must return an error matching ErrUnsupported, without writing anything.
+	WriteBatch(batchID string, samples []Sample) error
+	// Query sends a query on the selector's path with the selector's optional part as predicate.
+	// handler is called for each Reply, the last one being of kind ReplyFinal.
+	Query(path string, predicate string, handler ReplyHandler) error
+	// DeclareSubscriber declares a subscriber on a selector's path. handler is called for each received Sample.
+	DeclareSubscriber(path string, handler SampleHandler) (TransportSubscriber, error)
+	// UndeclareSubscriber undeclares a subscriber returned by DeclareSubscriber.
+	UndeclareSubscriber(sub TransportSubscriber) error
+	// DeclareEval declares an eval on a path. handler is called for each query on this path.
+	DeclareEval(path string, handler QueryHandler) (TransportEval, error)
+	// UndeclareEval undeclares an eval returned by DeclareEval.
+	UndeclareEval(eval TransportEval) error
+	// Info returns the information about the session (User excepted).
+	Info() *SessionInfo
+	// Close terminates the session.
+	Close() error
+}
+
+// Pinger is implemented by the Transports able to check that their session is alive.
+// Yaks pings such a Transport periodically (see LoginOptions.KeepAlive), to detect a lost session
+// even when no operation is performed (e.g. when only subscribing or serving evals).
+type Pinger interface {
+	// Ping returns an error if the session doesn't respond within timeout.
+	Ping(timeout time.Duration) error
+}
+
+// UpdateMerger is implemented by the Transports whose storages merge the updates with the values they
+// store (see Merge). With the other Transports, Workspace.Update merges the updates on the client side.
+type UpdateMerger interface {
+	// MergesUpdates returns true if the storages reached by the Transport merge the updates.
+	MergesUpdates() bool
+}
+
+// TransportSubscriber is a subscriber declared on a Transport. Its actual type depends on the Transport.
+type TransportSubscriber interface{}
+
+// TransportEval is an eval declared on a Transport. Its actual type depends on the Transport.
+type TransportEval interface{}
+
+// Sample is a data transiting via a Transport
+type Sample struct {
+	Path      string
+	Data      []byte
+	Encoding  Encoding
+	Kind      ChangeKind
+	Timestamp Timestamp
+	// BatchID is the id of the batch the sample was written with (empty if not written with WriteBatch)
+	BatchID string
+	// BatchSize is the number of samples of the batch received by the subscriber (0 if not in a batch)
+	BatchSize int
+}
+
+// SampleHandler defines the callback function called by a Transport for each Sample received by a subscriber.
+type SampleHandler func(sample *Sample)
+
+// ReplyKind is the kind of a Reply to a query
+type ReplyKind uint8
+
+const (
+	// ReplyStorageData is a reply with data from a storage
+	ReplyStorageData ReplyKind = iota
+	// ReplyStorageFinal is the final reply from a storage (without data)
+	ReplyStorageFinal
+	// ReplyEvalData is a reply with data from an eval
+	ReplyEvalData
+	// ReplyEvalFinal is the final reply from an eval (without data)
+	ReplyEvalFinal
+	// ReplyFinal is the final reply to a query (without data)
+	ReplyFinal
+)
+
+// Reply is a reply to a query sent via a Transport
+type Reply struct {
+	// Kind is the kind of reply. Only the ReplyStorageData and ReplyEvalData replies have the other fields set.
+	Kind ReplyKind
+	// SourceID is the id of the storage or eval that sent the reply (if known)
+	SourceID  []byte
+	Path      string
+	Data      []byte
+	Encoding  Encoding
+	Timestamp Timestamp
+}
+
+// ReplyHandler defines the callback function called by a Transport for each Reply to a query.
+type ReplyHandler func(reply *Reply)
+
+// RepliesSender defines the function to be called by a QueryHandler to send the replies to a query.
+// Only the Path, Data, Encoding and Kind of each Sample are sent.
+type RepliesSender func(replies []Sample)
+
+// QueryHandler defines the callback function called by a Transport for each query received by an eval.
+// It must call sendReplies, possibly with no replies.
+type QueryHandler func(path string, predicate string, sendReplies RepliesSender)
@@ -0,0 +1,109 @@
+package yaks
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// expiry is the expiry of a value put with Workspace.PutWithTTL
+type expiry struct {
+	at       time.Time
+	encoding Encoding
+	data     []byte
+	// ts is the Timestamp of the value put (nil if unknown)
+	ts    *Timestamp
+	timer *time.Timer
+}
+
+// isExpired returns true if the Entry e has the value that expires, and if it's expired at t.
+// If the Timestamp of the value put is known, the value of e must have this Timestamp: the same value
+// put again afterwards doesn't expire. Otherwise, the value of e must have the same encoding and data.
+func (x *expiry) isExpired(e *Entry, t time.Time) bool {
+	if t.Before(x.at) {
+		return false
+	}
+	if x.ts != nil {
+		return *e.Timestamp() == *x.ts
+	}
+	return x.encoding == e.Value().Encoding() && bytes.Equal(x.data, e.Value().Encode())
+}
+
+// PutWithTTL puts a path/value into Yaks, expiring after ttl: the value is put as is (with its own
+// encoding), and the expiry is recorded by the Workspace. When the ttl lapses, the Workspace removes
+// the path, unless its value was changed in the meantime (i.e. it's not the value put with the ttl anymore).
+// To tell the value put from the same value put again afterwards, its Timestamp is retrieved with a Get
+// after the put (if no storage replies with it, only a different value prevents the removal).
+// Calling PutWithTTL again on the same path reschedules the removal. The scheduled removals are cancelled
+// when the Workspace is closed (with Close or Logout).
+func (w *Workspace) PutWithTTL(path *Path, value Value, ttl time.Duration) error {
+	return w.PutWithTTLContext(context.Background(), path, value, ttl)
+}
+
+// PutWithTTLContext is the same as PutWithTTL, with a context as for PutContext.
+func (w *Workspace) PutWithTTLContext(ctx context.Context, path *Path, value Value, ttl time.Duration) error {
+	p := w.toAbsolutePath(path)
+	at := time.Now().Add(ttl)
+	if err := w.PutContext(ctx, p, value); err != nil {
+		return err
+	}
+	x := &expiry{at: at, encoding: value.Encoding(), data: value.Encode()}
+	if current, err := w.currentEntry(ctx, "PutWithTTL", p); err != nil {
+		logger.WithField("path", p).WithField("error", err).Warn("Failed to get the Timestamp of a value put with a TTL")
+	} else if current != nil && x.encoding == current.Value().Encoding() && bytes.Equal(x.data, current.Value().Encode()) {
+		x.ts = current.Timestamp()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	if old, ok := w.expiries[*p]; ok {
+		old.timer.Stop()
+	}
+	x.timer = time.AfterFunc(time.Until(at), func() {
+		w.mu.Lock()
+		rescheduled := w.expiries[*p] != x
+		w.mu.Unlock()
+		if rescheduled {
+			// or closed
+			return
+		}
+		w.expire(p, x)
+		// the expiry is kept until the removal, for GetOptions.HideExpired
+		w.mu.Lock()
+		if w.expiries[*p] == x {
+			delete(w.expiries, *p)
+		}
+		w.mu.Unlock()
+	})
+	w.expiries[*p] = x
+	return nil
+}
+
+// isExpired returns true if the value of the Entry e was put with PutWithTTL by this Workspace, and is expired at t
+// (i.e. it's not removed yet)
+func (w *Workspace) isExpired(e *Entry, t time.Time) bool {
+	w.mu.Lock()
+	x, ok := w.expiries[*e.Path()]
+	w.mu.Unlock()
+	return ok && x.isExpired(e, t)
+}
+
+// expire removes path, unless its current value is not the one that expired with x
+func (w *Workspace) expire(path *Path, x *expiry) {
+	logger := logger.WithField("path", path)
+	current, err := w.currentEntry(context.Background(), "PutWithTTL", path)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to get the value to be expired")
+		return
+	}
+	if current != nil && !x.isExpired(current, time.Now()) {
+		logger.Debug("Value changed before its expiry")
+		return
+	}
+	logger.Debug("Remove expired value")
+	if err := w.Remove(path); err != nil {
+		logger.WithField("error", err).Warn("Failed to remove expired value")
+	}
+}
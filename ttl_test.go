@@ -0,0 +1,117 @@
+package yaks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+func TestPutWithTTL(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", nil)
+	w := s.Workspace("/")
+	if err := w.PutWithTTL(path(t, "/a/x"), yaks.NewJSONValue(`{"a":1}`), 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.PutWithTTL(path(t, "/a/y"), yaks.NewStringValue("1"), 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// the value is put with its own encoding and type
+	entries, _ := w.GetWithOptions(selector(t, "/a/x"), nil)
+	if len(entries) != 1 || entries[0].Value().Encoding() != yaks.JSON {
+		t.Fatalf("entries = %v, want the JSON value", entries)
+	}
+	if _, ok := entries[0].Value().(*yaks.StringValue); !ok {
+		t.Errorf("value put with a TTL decoded to %T", entries[0].Value())
+	}
+	// /a/y is changed before its expiry, by another Workspace
+	s.Workspace("/").Put(path(t, "/a/y"), yaks.NewStringValue("2"))
+
+	if op := s.AwaitChange("/a/x"); op.Kind != yakstest.OpPut {
+		t.Fatalf("first change on /a/x = %+v", op)
+	}
+	if op := s.AwaitChange("/a/x"); op.Kind != yakstest.OpRemove {
+		t.Errorf("change on /a/x after the TTL = %+v, want a remove", op)
+	}
+	entries, _ = w.GetWithOptions(selector(t, "/a/*"), nil)
+	if len(entries) != 1 || entries[0].Path().ToString() != "/a/y" || entries[0].Value().ToString() != "2" {
+		t.Errorf("entries after the TTL = %v, want only the changed /a/y", entries)
+	}
+}
+
+func TestPutWithTTLRefreshed(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", nil)
+	w := s.Workspace("/")
+	if err := w.PutWithTTL(path(t, "/a/x"), yaks.NewStringValue("1"), 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// the same value is put again before the expiry, by another Workspace
+	s.Workspace("/").Put(path(t, "/a/x"), yaks.NewStringValue("1"))
+
+	time.Sleep(150 * time.Millisecond)
+	for _, op := range s.Ops() {
+		if op.Kind == yakstest.OpRemove {
+			t.Errorf("refreshed value removed: %+v", op)
+		}
+	}
+	entries, _ := w.GetWithOptions(selector(t, "/a/x"), nil)
+	if len(entries) != 1 {
+		t.Errorf("entries = %v, want the refreshed /a/x", entries)
+	}
+}
+
+// removeBlocker is a session with a memory.Engine whose removes are blocked until release is closed
+// (signaling their path on removing when they start)
+type removeBlocker struct {
+	yaks.Transport
+	removing chan string
+	release  chan struct{}
+}
+
+func (t *removeBlocker) WriteDataWO(path string, payload []byte, encoding yaks.Encoding, kind yaks.ChangeKind) error {
+	if kind == yaks.REMOVE {
+		t.removing <- path
+		<-t.release
+	}
+	return t.Transport.WriteDataWO(path, payload, encoding, kind)
+}
+
+func TestGetHideExpired(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", nil)
+	tr := &removeBlocker{s.Engine().NewTransport(), make(chan string, 1), make(chan struct{})}
+	y, err := yaks.NewWithTransport(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Logout()
+	defer close(tr.release)
+	w := y.Workspace(path(t, "/"))
+	w.Put(path(t, "/a/y"), yaks.NewStringValue("2"))
+	if err := w.PutWithTTL(path(t, "/a/x"), yaks.NewStringValue("1"), 30*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	hide := &yaks.GetOptions{HideExpired: true}
+	if entries, _ := w.GetWithOptions(selector(t, "/a/*"), hide); len(entries) != 2 {
+		t.Errorf("entries before the expiry = %v, want /a/x and /a/y", entries)
+	}
+
+	select {
+	case <-tr.removing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expired value not removed")
+	}
+	// the removal is blocked: the expired value is still stored
+	if entries, _ := w.GetWithOptions(selector(t, "/a/*"), hide); len(entries) != 1 || entries[0].Path().ToString() != "/a/y" {
+		t.Errorf("entries with HideExpired = %v, want only /a/y", entries)
+	}
+	if entries, _ := w.GetWithOptions(selector(t, "/a/*"), nil); len(entries) != 2 {
+		t.Errorf("entries = %v, want the expired /a/x and /a/y", entries)
+	}
+}
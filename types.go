@@ -1,25 +1,25 @@
 package yaks
 
 import (
+	"encoding/hex"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
-
-	"github.com/atolab/zenoh-go"
+	"time"
 )
 
-// Timestamp is a Zenoh Timestamp
-type Timestamp = zenoh.Timestamp
-
 // Properties is a (string,string) map
 type Properties map[string]string
 
 // Listener defines the callback function that has to be registered for subscriptions
 type Listener func([]Change)
 
-// SubscriptionID identifies a Yaks subscription
-type SubscriptionID = zenoh.Subscriber
+// SubscriptionID identifies a Yaks subscription.
+// Notice that it's opaque: it's no longer an alias of zenoh.Subscriber, as the core package doesn't depend on Zenoh.
+type SubscriptionID struct {
+	selector string
+}
 
 // Eval defines the callback function that has to be registered for evals
 type Eval func(path *Path, props Properties) Value
@@ -37,12 +37,12 @@ type Path struct {
 // Otherwise, it returns an error.
 func NewPath(p string) (*Path, error) {
 	if len(p) == 0 {
-		return nil, &YError{"Invalid path (empty String)", nil}
+		return nil, &YError{msg: "empty String", kind: ErrInvalidPath}
 	}
 
 	for i, c := range p {
 		if c == '?' || c == '#' || c == '[' || c == ']' || c == '*' {
-			return nil, &YError{"Invalid path: " + p + " (forbidden character at index " + strconv.Itoa(i) + ")", nil}
+			return nil, &YError{Path: p, msg: p + ": forbidden character at index " + strconv.Itoa(i), kind: ErrInvalidPath}
 		}
 	}
 	result := removeUselessSlashes(p)
@@ -105,11 +105,11 @@ var pattern = regexp.MustCompile(
 // Otherwise, it returns an error.
 func NewSelector(s string) (*Selector, error) {
 	if len(s) == 0 {
-		return nil, &YError{"Invalid selector (empty String)", nil}
+		return nil, &YError{msg: "empty String", kind: ErrInvalidSelector}
 	}
 
 	if !pattern.MatchString(s) {
-		return nil, &YError{"Invalid selector (not matching regex)", nil}
+		return nil, &YError{Path: s, msg: s + ": not matching regex", kind: ErrInvalidSelector}
 	}
 
 	groups := pattern.FindStringSubmatch(s)
@@ -180,15 +180,111 @@ func (s *Selector) AddPrefix(prefix *Path) *Selector {
 	return newSelector(prefix.path+s.path, s.predicate, s.properties, s.fragment)
 }
 
+///////////////////
+//   Timestamp   //
+///////////////////
+
+// Timestamp is a Yaks timestamp: a time (in 64-bit NTP format) and the id of the clock that created it.
+// Notice that it's no longer an alias of zenoh.Timestamp, as the core package doesn't depend on Zenoh,
+// but it has the same methods (ClockID, Time, GoTime, Before and String).
+type Timestamp struct {
+	time    uint64
+	clockID [16]byte
+}
+
+// number of NTP fraction per second (2^32)
+const fracPerSec = 0x100000000
+
+// number of nanoseconds per second (10^9)
+const nanoPerSec = 1000000000
+
+// NewTimestamp returns a new Timestamp for the time t, created by the clock with id clockID
+func NewTimestamp(t time.Time, clockID [16]byte) Timestamp {
+	sec := uint64(t.Unix())
+	ns := uint64(t.Nanosecond())
+	// round up, so that GoTime() returns t
+	frac := ((ns << 32) + nanoPerSec - 1) / nanoPerSec
+	return Timestamp{sec<<32 | frac, clockID}
+}
+
+// NewNTPTimestamp returns a new Timestamp for the time ntp (in 64-bit NTP format), created by the clock
+// with id clockID (e.g. to convert the timestamps of a Transport).
+func NewNTPTimestamp(ntp uint64, clockID [16]byte) Timestamp {
+	return Timestamp{ntp, clockID}
+}
+
+// ClockID returns the clock id of a Timestamp
+func (ts *Timestamp) ClockID() [16]byte {
+	return ts.clockID
+}
+
+// Time returns the time of a Timestamp (in 64-bit NTP format)
+func (ts *Timestamp) Time() uint64 {
+	return ts.time
+}
+
+// GoTime returns the time of a Timestamp as a Go time.Time
+func (ts *Timestamp) GoTime() time.Time {
+	sec := ts.time >> 32
+	frac := ts.time & 0xffffffff
+	ns := (frac * nanoPerSec) / fracPerSec
+	return time.Unix(int64(sec), int64(ns))
+}
+
+// Before reports whether the Timestamp ts was created before ots.
+// This function can be used for sorting.
+func (ts *Timestamp) Before(ots *Timestamp) bool {
+	if ts.time != ots.time {
+		return ts.time < ots.time
+	}
+	for i, b := range ts.clockID {
+		if b != ots.clockID[i] {
+			return b < ots.clockID[i]
+		}
+	}
+	return false
+}
+
+// String returns the Timestamp as a string
+func (ts *Timestamp) String() string {
+	return ts.GoTime().In(time.UTC).Format(time.RFC3339Nano) + "/" + hex.EncodeToString(ts.clockID[:])
+}
+
 ///////////////
 //   Entry   //
 ///////////////
 
+// SourceKind is the kind of source that replied an Entry
+type SourceKind uint8
+
+const (
+	// SourceUnknown means the source of the Entry is unknown
+	SourceUnknown SourceKind = iota
+	// SourceStorage means the Entry was replied by a storage
+	SourceStorage
+	// SourceEval means the Entry was replied by an eval
+	SourceEval
+)
+
+// String returns the SourceKind as a string
+func (k SourceKind) String() string {
+	switch k {
+	case SourceStorage:
+		return "storage"
+	case SourceEval:
+		return "eval"
+	default:
+		return "unknown"
+	}
+}
+
 // Entry is a Path + Value + Timestamp tuple
 type Entry struct {
-	path   *Path
-	value  Value
-	tstamp *Timestamp
+	path       *Path
+	value      Value
+	tstamp     *Timestamp
+	sourceKind SourceKind
+	sourceID   string
 }
 
 // Path returns the path of the Entry
@@ -206,6 +302,17 @@ func (e *Entry) Timestamp() *Timestamp {
 	return e.tstamp
 }
 
+// SourceKind returns the kind of source (storage or eval) that replied the Entry
+func (e *Entry) SourceKind() SourceKind {
+	return e.sourceKind
+}
+
+// SourceID returns the identity of the source that replied the Entry, as an hexadecimal string
+// (empty if not provided by the Transport)
+func (e *Entry) SourceID() string {
+	return e.sourceID
+}
+
 ////////////////
 //   Change   //
 ////////////////
@@ -224,10 +331,12 @@ const (
 
 // Change represents a change made on a path/value in Yaks
 type Change struct {
-	path  *Path
-	kind  ChangeKind
-	time  uint64
-	value Value
+	path    *Path
+	kind    ChangeKind
+	time    uint64
+	value   Value
+	delta   Value
+	batchID string
 }
 
 // Path returns the path impacted by the change
@@ -245,11 +354,22 @@ func (c *Change) Time() uint64 {
 	return c.time
 }
 
-// Value returns the value that changed
+// Value returns the value that changed.
+// For an UPDATE notified to a listener subscribed with Workspace.SubscribeMerged, it's the merged value.
 func (c *Change) Value() Value {
 	return c.value
 }
 
+// Delta returns the value as sent by the writer (i.e. the delta for an UPDATE).
+func (c *Change) Delta() Value {
+	return c.delta
+}
+
+// BatchID returns the id of the batch the change is part of (empty if not made by Workspace.PutBatch)
+func (c *Change) BatchID() string {
+	return c.batchID
+}
+
 ////////////////
 //  Encoding  //
 ////////////////
@@ -271,17 +391,31 @@ var valueDecoders = map[Encoding]ValueDecoder{}
 // RegisterValueDecoder registers a ValueDecoder function with it's Encoding
 func RegisterValueDecoder(encoding Encoding, decoder ValueDecoder) error {
 	if valueDecoders[encoding] != nil {
-		return &YError{"Already registered ValueDecoder for Encoding " + strconv.Itoa(int(encoding)), nil}
+		return &YError{msg: "already registered ValueDecoder for Encoding " + strconv.Itoa(int(encoding))}
 	}
 	valueDecoders[encoding] = decoder
 	return nil
 }
 
+// DecodeValue decodes data using the ValueDecoder registered for encoding.
+// If no ValueDecoder is registered for encoding, an error matching ErrUnknownEncoding is returned.
+func DecodeValue(encoding Encoding, data []byte) (Value, error) {
+	decoder, ok := valueDecoders[encoding]
+	if !ok {
+		return nil, &YError{msg: "no ValueDecoder registered for Encoding " + strconv.Itoa(int(encoding)), kind: ErrUnknownEncoding}
+	}
+	value, err := decoder(data)
+	if err != nil {
+		return nil, &YError{kind: ErrDecode, cause: err}
+	}
+	return value, nil
+}
+
 func init() {
 	RegisterValueDecoder(RAW, rawDecoder)
 	RegisterValueDecoder(STRING, stringDecoder)
 	RegisterValueDecoder(PROPERTIES, propertiesDecoder)
-	RegisterValueDecoder(JSON, stringDecoder)
+	RegisterValueDecoder(JSON, jsonDecoder)
 }
 
 ////////////////
@@ -335,18 +469,22 @@ func rawDecoder(buf []byte) (Value, error) {
 //   STRING Value   //
 //////////////////////
 
-// StringValue is a STRING value (i.e. just a string)
+// StringValue is a STRING value (i.e. just a string), or a JSON value (see NewJSONValue)
 type StringValue struct {
-	s string
+	s    string
+	json bool
 }
 
 // NewStringValue returns a new StringValue
 func NewStringValue(s string) *StringValue {
-	return &StringValue{s}
+	return &StringValue{s: s}
 }
 
-// Encoding returns the encoding flag for a StringValue
+// Encoding returns the encoding flag for a StringValue (JSON if created with NewJSONValue or decoded from JSON)
 func (v *StringValue) Encoding() Encoding {
+	if v.json {
+		return JSON
+	}
 	return STRING
 }
 
@@ -361,7 +499,7 @@ func (v *StringValue) ToString() string {
 }
 
 func stringDecoder(buf []byte) (Value, error) {
-	return &StringValue{string(buf)}, nil
+	return &StringValue{s: string(buf)}, nil
 }
 
 //////////////////////////
@@ -427,3 +565,17 @@ func propertiesOfString(s string) Properties {
 func propertiesDecoder(buf []byte) (Value, error) {
 	return &PropertiesValue{propertiesOfString(string(buf))}, nil
 }
+
+////////////////////
+//   JSON Value   //
+////////////////////
+
+// NewJSONValue returns a new JSON value (i.e. a string containing a JSON document).
+// As the JSON values are decoded, it's a StringValue with the JSON encoding.
+func NewJSONValue(s string) *StringValue {
+	return &StringValue{s: s, json: true}
+}
+
+func jsonDecoder(buf []byte) (Value, error) {
+	return NewJSONValue(string(buf)), nil
+}
@@ -0,0 +1,63 @@
+package yaks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestampGoTime(t *testing.T) {
+	clockID := [16]byte{0xca, 0xfe}
+	times := []time.Time{
+		time.Unix(0, 0),
+		time.Unix(1, 1),
+		time.Unix(1600000000, 123456789),
+		time.Unix(1600000000, 999999999),
+		time.Date(2030, 1, 2, 3, 4, 5, 500000000, time.UTC),
+		time.Now(),
+	}
+	for _, tm := range times {
+		ts := NewTimestamp(tm, clockID)
+		if got := ts.GoTime(); !got.Equal(tm) {
+			t.Errorf("NewTimestamp(%v).GoTime() = %v", tm, got)
+		}
+		if ts.ClockID() != clockID {
+			t.Errorf("NewTimestamp(%v).ClockID() = %x", tm, ts.ClockID())
+		}
+		if sec := ts.Time() >> 32; sec != uint64(tm.Unix()) {
+			t.Errorf("NewTimestamp(%v) has %d seconds, want %d", tm, sec, tm.Unix())
+		}
+	}
+}
+
+func TestTimestampNTP(t *testing.T) {
+	// 1.5 second in 64-bit NTP format
+	ts := NewNTPTimestamp(1<<32|1<<31, [16]byte{})
+	if got := ts.GoTime(); !got.Equal(time.Unix(1, 500000000)) {
+		t.Errorf("GoTime() = %v, want 1.5s", got)
+	}
+	if got := NewTimestamp(ts.GoTime(), [16]byte{}); got != ts {
+		t.Errorf("NewTimestamp(GoTime()) = %v, want %v", got.Time(), ts.Time())
+	}
+}
+
+func TestTimestampBefore(t *testing.T) {
+	tm := time.Unix(1600000000, 0)
+	a := NewTimestamp(tm, [16]byte{1})
+	b := NewTimestamp(tm, [16]byte{2})
+	c := NewTimestamp(tm.Add(time.Nanosecond), [16]byte{0})
+	tests := []struct {
+		x, y *Timestamp
+		want bool
+	}{
+		{&a, &b, true},
+		{&b, &a, false},
+		{&a, &a, false},
+		{&b, &c, true},
+		{&c, &a, false},
+	}
+	for i, tt := range tests {
+		if got := tt.x.Before(tt.y); got != tt.want {
+			t.Errorf("%d: %v.Before(%v) = %v, want %v", i, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package yaks_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/memory"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+func TestSubscribeMerged(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", map[string]yaks.Value{"/a/x": yaks.NewJSONValue(`{"a":1}`)})
+	w := s.Workspace("/")
+	c := newChanges()
+	if _, err := w.SubscribeMerged(selector(t, "/a/*"), c.listener); err != nil {
+		t.Fatal(err)
+	}
+	w.Update(path(t, "/a/x"), yaks.NewJSONValue(`{"b":2}`))
+	received := c.await(t, 1)
+	change := received[0][0]
+	if change.Value().ToString() != `{"a":1,"b":2}` || change.Delta().ToString() != `{"b":2}` {
+		t.Errorf("merged change = %s (delta %s)", change.Value().ToString(), change.Delta().ToString())
+	}
+}
+
+// nonMergingTransport is a session with a memory.Engine which isn't an UpdateMerger,
+// running concurrentWrite (if not nil) before the forwarding of the n-th query
+type nonMergingTransport struct {
+	yaks.Transport
+	mu              sync.Mutex
+	queries         int
+	kinds           []yaks.ChangeKind
+	concurrentWrite func(n int)
+}
+
+func (t *nonMergingTransport) WriteDataWO(path string, payload []byte, encoding yaks.Encoding, kind yaks.ChangeKind) error {
+	t.mu.Lock()
+	t.kinds = append(t.kinds, kind)
+	t.mu.Unlock()
+	return t.Transport.WriteDataWO(path, payload, encoding, kind)
+}
+
+func (t *nonMergingTransport) Query(path string, predicate string, handler yaks.ReplyHandler) error {
+	t.mu.Lock()
+	t.queries++
+	n := t.queries
+	t.mu.Unlock()
+	if t.concurrentWrite != nil {
+		t.concurrentWrite(n)
+	}
+	return t.Transport.Query(path, predicate, handler)
+}
+
+func newNonMergingWorkspace(t *testing.T, e *memory.Engine, concurrentWrite func(n int)) (*yaks.Yaks, *yaks.Workspace, *nonMergingTransport) {
+	t.Helper()
+	tr := &nonMergingTransport{Transport: e.NewTransport(), concurrentWrite: concurrentWrite}
+	y, err := yaks.NewWithTransport(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return y, y.Workspace(path(t, "/")), tr
+}
+
+func TestUpdateMergedByClient(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", map[string]yaks.Value{"/a/x": yaks.NewJSONValue(`{"a":1}`)})
+	e := s.Engine()
+	other := e.NewTransport()
+
+	// another write between the Get of the current value and the Get of PutIf
+	y, w, tr := newNonMergingWorkspace(t, e, func(n int) {
+		if n == 2 {
+			other.WriteDataWO("/a/x", []byte(`{"c":3}`), yaks.JSON, yaks.PUT)
+		}
+	})
+	defer y.Logout()
+	if err := w.Update(path(t, "/a/x"), yaks.NewJSONValue(`{"b":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := w.GetWithOptions(selector(t, "/a/x"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Value().ToString() != `{"b":2,"c":3}` {
+		t.Errorf("entries = %v, want the update merged with the latest value", entries)
+	}
+	if len(tr.kinds) != 1 || tr.kinds[0] != yaks.PUT {
+		t.Errorf("written kinds = %v, want a single PUT", tr.kinds)
+	}
+
+	// the value keeps changing
+	y, w, tr = newNonMergingWorkspace(t, e, func(n int) {
+		if n > 1 {
+			other.WriteDataWO("/a/x", []byte(`{"c":3}`), yaks.JSON, yaks.PUT)
+		}
+	})
+	defer y.Logout()
+	var yerr *yaks.YError
+	if err := w.Update(path(t, "/a/x"), yaks.NewJSONValue(`{"b":2}`)); !errors.Is(err, yaks.ErrConflict) ||
+		!errors.As(err, &yerr) || yerr.Op != "Update" {
+		t.Errorf("Update of a changing value: %v, want an Update error matching ErrConflict", err)
+	}
+	if len(tr.kinds) != 0 {
+		t.Errorf("written kinds = %v, want none", tr.kinds)
+	}
+}
@@ -1,58 +1,537 @@
 package yaks
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/atolab/zenoh-go"
 	log "github.com/sirupsen/logrus"
 )
 
 // Workspace represents a workspace to operate on Yaks.
 type Workspace struct {
 	path          *Path
-	zenoh         *zenoh.Zenoh
-	evals         map[Path]*zenoh.Eval
+	yaks          *Yaks
+	mu            sync.Mutex
+	subs          map[*SubscriptionID]*subscription
+	evals         map[Path]*registeredEval
 	useSubroutine bool
+	closed        bool
+	callbacks     sync.WaitGroup
+	inflight      chan struct{}
+	lastAsync     *WriteResult
+	expiries      map[Path]*expiry
+	// undeclarations to be done once the Transport being restored becomes the current one
+	pendingUndeclares []pendingUndeclare
+}
+
+// subscription is a subscription declared in a Workspace, kept to be re-declared after a reconnection
+type subscription struct {
+	resource string
+	handler  SampleHandler
+	t        Transport
+	tsub     TransportSubscriber
+}
+
+// registeredEval is an eval declared in a Workspace, kept to be re-declared after a reconnection
+type registeredEval struct {
+	resource string
+	handler  QueryHandler
+	t        Transport
+	teval    TransportEval
+}
+
+// pendingUndeclare is a subscriber or an eval re-declared on a Transport being restored,
+// that has been unsubscribed or unregistered before the end of the restoration
+type pendingUndeclare struct {
+	t     Transport
+	tsub  TransportSubscriber
+	teval TransportEval
+}
+
+func newWorkspace(path *Path, y *Yaks, useSubroutine bool, maxInFlight int) *Workspace {
+	return &Workspace{
+		path:          path,
+		yaks:          y,
+		subs:          make(map[*SubscriptionID]*subscription),
+		evals:         make(map[Path]*registeredEval),
+		useSubroutine: useSubroutine,
+		inflight:      make(chan struct{}, maxInFlight),
+		expiries:      make(map[Path]*expiry),
+	}
+}
+
+// redeclare declares again all the subscriptions and evals of this Workspace on the Transport t
+func (w *Workspace) redeclare(t Transport) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, sub := range w.subs {
+		tsub, err := t.DeclareSubscriber(sub.resource, sub.handler)
+		if err != nil {
+			return &YError{Op: "Subscribe", Path: sub.resource, cause: err}
+		}
+		sub.t = t
+		sub.tsub = tsub
+	}
+	for _, e := range w.evals {
+		teval, err := t.DeclareEval(e.resource, e.handler)
+		if err != nil {
+			return &YError{Op: "RegisterEval", Path: e.resource, cause: err}
+		}
+		e.t = t
+		e.teval = teval
+	}
+	return nil
+}
+
+// restored must be called once the Transport t, on which the Workspace has been re-declared, is the
+// current one. It undeclares from t the subscribers and evals that were removed meanwhile.
+func (w *Workspace) restored(t Transport) {
+	w.mu.Lock()
+	pending := w.pendingUndeclares
+	w.pendingUndeclares = nil
+	w.mu.Unlock()
+	for _, u := range pending {
+		if u.t != t {
+			// re-declared on a Transport whose restoration failed (and that has been closed)
+			continue
+		}
+		var err error
+		if u.tsub != nil {
+			err = t.UndeclareSubscriber(u.tsub)
+		} else {
+			err = t.UndeclareEval(u.teval)
+		}
+		if err != nil {
+			logger.WithField("error", err).Warn("Failed to undeclare a subscriber or an eval removed during the reconnection")
+		}
+	}
+}
+
+// undeclarable returns true if the subscriber or eval declared on the Transport t can be undeclared now.
+// Otherwise, the session with Yaks is being re-established: if t is the Transport being restored,
+// the undeclaration u is postponed until its restoration completes (see restored),
+// else t is the lost Transport and there is nothing to undeclare.
+// It must be called with w.mu held, so that it's not concurrent with redeclare.
+func (w *Workspace) undeclarable(t Transport, u pendingUndeclare) bool {
+	current, state := w.yaks.sessionState()
+	if state == StateConnected {
+		return true
+	}
+	if state == StateReconnecting && t != current {
+		w.pendingUndeclares = append(w.pendingUndeclares, u)
+	}
+	return false
+}
+
+// runCallback runs a listener or eval callback f, in its own subroutine if the Workspace has an executor.
+// It returns false (without running f) if the Workspace is closed.
+func (w *Workspace) runCallback(f func()) bool {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return false
+	}
+	w.callbacks.Add(1)
+	w.mu.Unlock()
+
+	run := func() {
+		defer w.callbacks.Done()
+		f()
+	}
+	if w.useSubroutine {
+		go run()
+	} else {
+		run()
+	}
+	return true
+}
+
+// connectedSession returns the current Transport to perform op on path, or an error matching ErrClosed
+// if the Workspace is closed (see Yaks.connectedSession).
+func (w *Workspace) connectedSession(op string, path string) (Transport, error) {
+	if w.isClosed() {
+		return nil, closedError(op, path)
+	}
+	return w.yaks.connectedSession(op, path)
+}
+
+// querySession returns the current Transport to perform op on path and a channel closed when it's lost,
+// or an error matching ErrClosed if the Workspace is closed (see Yaks.querySession).
+func (w *Workspace) querySession(op string, path string) (Transport, <-chan struct{}, error) {
+	if w.isClosed() {
+		return nil, nil, closedError(op, path)
+	}
+	return w.yaks.querySession(op, path)
+}
+
+func (w *Workspace) isClosed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+// Close closes the Workspace, which stops being tracked by its Yaks.
+// All its subscriptions and evals are undeclared, and the subscription listeners and eval callbacks that
+// are running are awaited until DefaultLogoutTimeout expires. Afterwards, all the operations on this
+// Workspace return ErrClosed. Closing a Workspace twice, or after Logout, has no effect.
+// Notice that calling this operation from a listener or a callback makes it wait until the timeout expires.
+func (w *Workspace) Close() error {
+	if !w.yaks.removeWorkspace(w) {
+		return nil
+	}
+	return w.close(nil, time.Now().Add(DefaultLogoutTimeout))
+}
+
+// close undeclares all the subscriptions and evals of this Workspace, cancels the expiries,
+// and waits until the deadline for the completion of the running callbacks.
+// If t is not nil, they are undeclared from t (Logout); otherwise, from the Transport they were
+// declared on, as Unsubscribe does. Afterwards, all the operations on this Workspace return ErrClosed.
+func (w *Workspace) close(t Transport, deadline time.Time) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	var undeclares []pendingUndeclare
+	for _, sub := range w.subs {
+		u := pendingUndeclare{t: t, tsub: sub.tsub}
+		if t == nil {
+			u.t = sub.t
+			if !w.undeclarable(sub.t, u) {
+				continue
+			}
+		}
+		undeclares = append(undeclares, u)
+	}
+	for _, e := range w.evals {
+		u := pendingUndeclare{t: t, teval: e.teval}
+		if t == nil {
+			u.t = e.t
+			if !w.undeclarable(e.t, u) {
+				continue
+			}
+		}
+		undeclares = append(undeclares, u)
+	}
+	w.subs = make(map[*SubscriptionID]*subscription)
+	w.evals = make(map[Path]*registeredEval)
+	for _, x := range w.expiries {
+		x.timer.Stop()
+	}
+	w.expiries = make(map[Path]*expiry)
+	w.mu.Unlock()
+
+	for _, u := range undeclares {
+		if u.tsub != nil {
+			if err := u.t.UndeclareSubscriber(u.tsub); err != nil {
+				logger.WithField("error", err).Warn("Failed to undeclare subscriber at Workspace closure")
+			}
+		} else if err := u.t.UndeclareEval(u.teval); err != nil {
+			logger.WithField("error", err).Warn("Failed to undeclare eval at Workspace closure")
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.callbacks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(time.Until(deadline)):
+		return &YError{Op: "Close", Path: w.path.ToString(), msg: "waiting for the callbacks", kind: ErrTimeout}
+	}
+}
+
+// contextError returns the error reporting that ctx is done while performing op on path.
+// It wraps ctx.Err() and, if the deadline of ctx is exceeded, it also matches ErrTimeout.
+func contextError(ctx context.Context, op string, path string) error {
+	err := ctx.Err()
+	if err == context.DeadlineExceeded {
+		return &YError{Op: op, Path: path, kind: ErrTimeout, cause: err}
+	}
+	return &YError{Op: op, Path: path, cause: err}
+}
+
+// runWithContext runs f (performing op on path) and waits for its completion or for ctx to be done,
+// whichever comes first. If ctx is done first, an error matching ctx.Err() is returned (see contextError)
+// and, if f eventually succeeds, undo is called to revert its effects (e.g. undeclaring a late subscriber).
+// If ctx can never be done, f is simply called in the current subroutine.
+func runWithContext(ctx context.Context, op string, path string, f func() error, undo func()) error {
+	if ctx.Err() != nil {
+		return contextError(ctx, op, path)
+	}
+	if ctx.Done() == nil {
+		return f()
+	}
+
+	mu := new(sync.Mutex)
+	abandoned := false
+	result := make(chan error, 1)
+	go func() {
+		err := f()
+		mu.Lock()
+		defer mu.Unlock()
+		if abandoned {
+			if err == nil {
+				undo()
+			}
+			return
+		}
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		mu.Lock()
+		defer mu.Unlock()
+		select {
+		case err := <-result:
+			// f completed while ctx was being cancelled
+			return err
+		default:
+		}
+		abandoned = true
+		return contextError(ctx, op, path)
+	}
 }
 
 // Put a path/value into Yaks.
 func (w *Workspace) Put(path *Path, value Value) error {
+	return w.PutContext(context.Background(), path, value)
+}
+
+// PutContext puts a path/value into Yaks.
+// If ctx is done before the put is sent, it returns an error matching ctx.Err()
+// (and ErrTimeout if the deadline of ctx is exceeded) and nothing is put.
+// Once sent, the put is not bound to ctx anymore.
+func (w *Workspace) PutContext(ctx context.Context, path *Path, value Value) error {
 	logger.WithFields(log.Fields{
 		"path":  path,
 		"value": value,
 	}).Debug("Put")
-	p := w.toAbsolutePath(path)
-	if e := w.zenoh.WriteDataWO(p.ToString(), value.Encode(), value.Encoding(), PUT); e != nil {
-		return &YError{"Put on " + p.ToString() + " failed", e}
-	}
-	return nil
+	return w.write(ctx, "Put", w.toAbsolutePath(path), value.Encode(), value.Encoding(), PUT)
 }
 
 // Update a path/value into Yaks.
+// The value is a delta to be merged with the current value of the path, as per Merge
+// (e.g. adding keys to a PropertiesValue or applying a JSON merge patch).
+// If the Transport doesn't merge the updates (see UpdateMerger), as with Zenoh, the update is merged on the
+// client side: the current value of path is retrieved with a Get, merged with value, and the result is put
+// as with PutIf (the subscribers receive a PUT of the merged value). If the current value is changed between
+// the Get and the put, the merge is retried a few times before returning an error matching ErrConflict.
 func (w *Workspace) Update(path *Path, value Value) error {
+	return w.UpdateContext(context.Background(), path, value)
+}
+
+// UpdateContext updates a path/value into Yaks.
+// If ctx is done before the update is sent, it returns an error matching ctx.Err()
+// (and ErrTimeout if the deadline of ctx is exceeded) and nothing is updated.
+// Once sent, the update is not bound to ctx anymore.
+func (w *Workspace) UpdateContext(ctx context.Context, path *Path, value Value) error {
 	logger.WithFields(log.Fields{
 		"path":  path,
 		"value": value,
 	}).Debug("Update")
 	p := w.toAbsolutePath(path)
-	if e := w.zenoh.WriteDataWO(p.ToString(), value.Encode(), value.Encoding(), UPDATE); e != nil {
-		return &YError{"Put on " + path.ToString() + " failed", e}
+	t, err := w.connectedSession("Update", p.ToString())
+	if err != nil {
+		return err
+	}
+	if m, ok := t.(UpdateMerger); ok && m.MergesUpdates() {
+		return w.write(ctx, "Update", p, value.Encode(), value.Encoding(), UPDATE)
+	}
+	return w.mergeUpdate(ctx, p, value)
+}
+
+// updateMergeAttempts is the number of attempts of an update merged on the client side
+const updateMergeAttempts = 3
+
+// mergeUpdate merges the update delta of the absolute path p with its current value and puts the result,
+// if the current value is not changed meanwhile
+func (w *Workspace) mergeUpdate(ctx context.Context, p *Path, delta Value) error {
+	if ctx.Err() != nil {
+		return contextError(ctx, "Update", p.ToString())
+	}
+	current, err := w.currentEntry(ctx, "Update", p)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		var base Value
+		var expected *Timestamp
+		if current != nil {
+			base = current.Value()
+			expected = current.Timestamp()
+		}
+		merged, err := Merge(base, delta)
+		if err != nil {
+			return &YError{Op: "Update", Path: p.ToString(), cause: err}
+		}
+		current, err = w.putIf(ctx, "Update", p, merged, expected)
+		if !errors.Is(err, ErrConflict) || attempt == updateMergeAttempts {
+			return err
+		}
+		logger.WithField("path", p).Debug("Update merge retried")
 	}
-	return nil
 }
 
 // Remove a path/value from Yaks.
 func (w *Workspace) Remove(path *Path) error {
+	return w.RemoveContext(context.Background(), path)
+}
+
+// RemoveContext removes a path/value from Yaks.
+// If ctx is done before the remove is sent, it returns an error matching ctx.Err()
+// (and ErrTimeout if the deadline of ctx is exceeded) and nothing is removed.
+// Once sent, the remove is not bound to ctx anymore.
+func (w *Workspace) RemoveContext(ctx context.Context, path *Path) error {
 	logger.WithField("path", path).Debug("Remove")
-	p := w.toAbsolutePath(path)
-	if e := w.zenoh.WriteDataWO(p.ToString(), nil, 0, REMOVE); e != nil {
-		return &YError{"Put on " + path.ToString() + " failed", e}
+	return w.write(ctx, "Remove", w.toAbsolutePath(path), nil, 0, REMOVE)
+}
+
+// write sends a change of kind on the absolute path p (for the operation op), unless ctx is already done.
+// The write itself can't be interrupted: a Transport sends it without waiting for an acknowledgment.
+func (w *Workspace) write(ctx context.Context, op string, p *Path, payload []byte, encoding Encoding, kind ChangeKind) error {
+	if ctx.Err() != nil {
+		return contextError(ctx, op, p.ToString())
+	}
+	t, err := w.connectedSession(op, p.ToString())
+	if err != nil {
+		return err
+	}
+	if e := t.WriteDataWO(p.ToString(), payload, encoding, kind); e != nil {
+		w.yaks.transportFailed(t, e)
+		return &YError{Op: op, Path: p.ToString(), cause: e}
 	}
 	return nil
 }
 
+// RemoveAllOptions are the options of Workspace.RemoveAll
+type RemoveAllOptions struct {
+	// BatchSize is the maximum number of removes sent at once with PutBatch (if 0, each path is removed separately).
+	// If the Transport doesn't support batches, the paths are removed separately.
+	BatchSize int
+}
+
+// RemoveResult is the result of the removal of a path by Workspace.RemoveAll
+type RemoveResult struct {
+	Path *Path
+	// Err is the error that occurred while removing the path (nil if it was removed)
+	Err error
+}
+
+// RemoveAll removes all the path/values matching the selector from Yaks.
+// The matching paths are listed with a Get, then each of them is removed once (in batches if options.BatchSize > 0).
+// options can be nil. It returns the result of the removal of each path, or an error if the Get failed.
+func (w *Workspace) RemoveAll(selector *Selector, options *RemoveAllOptions) ([]RemoveResult, error) {
+	return w.RemoveAllContext(context.Background(), selector, options)
+}
+
+// RemoveAllContext is the same as RemoveAll, with a context as for GetContext and RemoveContext.
+func (w *Workspace) RemoveAllContext(ctx context.Context, selector *Selector, options *RemoveAllOptions) ([]RemoveResult, error) {
+	logger.WithField("selector", selector).Debug("RemoveAll")
+	if options == nil {
+		options = new(RemoveAllOptions)
+	}
+	entries, err := w.GetContext(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	// the entries of a same path (e.g. a time series) are sorted together: remove each path once
+	var results []RemoveResult
+	for i, e := range entries {
+		if i == 0 || *e.Path() != *entries[i-1].Path() {
+			results = append(results, RemoveResult{Path: e.Path()})
+		}
+	}
+	removeEach := func(results []RemoveResult) {
+		for i := range results {
+			results[i].Err = w.RemoveContext(ctx, results[i].Path)
+		}
+	}
+	if options.BatchSize <= 0 {
+		removeEach(results)
+		return results, nil
+	}
+	for start := 0; start < len(results); start += options.BatchSize {
+		end := start + options.BatchSize
+		if end > len(results) {
+			end = len(results)
+		}
+		batch := NewBatch()
+		for _, r := range results[start:end] {
+			batch.Remove(r.Path)
+		}
+		if _, err := w.PutBatchContext(ctx, batch); errors.Is(err, ErrUnsupported) {
+			removeEach(results[start:])
+			break
+		} else if err != nil {
+			for i := start; i < end; i++ {
+				results[i].Err = err
+			}
+		}
+	}
+	return results, nil
+}
+
+// PutIf puts a path/value into Yaks only if the Timestamp of the current value of path is expected
+// (or, if expected is nil, only if path has no value).
+// Otherwise, nothing is put and an error matching ErrConflict is returned with the current Entry (nil if none).
+// Notice that the current value is retrieved with a Get before the put: this gives an optimistic
+// concurrency control, but a concurrent put could still occur between the Get and the put.
+func (w *Workspace) PutIf(path *Path, value Value, expected *Timestamp) (*Entry, error) {
+	return w.PutIfContext(context.Background(), path, value, expected)
+}
+
+// PutIfContext is the same as PutIf, with a context as for GetContext and PutContext.
+func (w *Workspace) PutIfContext(ctx context.Context, path *Path, value Value, expected *Timestamp) (*Entry, error) {
+	return w.putIf(ctx, "PutIf", w.toAbsolutePath(path), value, expected)
+}
+
+// putIf puts value on the absolute path p (for the operation op) if the Timestamp of its current value is expected
+func (w *Workspace) putIf(ctx context.Context, op string, p *Path, value Value, expected *Timestamp) (*Entry, error) {
+	current, err := w.currentEntry(ctx, op, p)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case current == nil && expected == nil:
+	case current != nil && expected != nil && *current.Timestamp() == *expected:
+	default:
+		logger.WithField("path", p).Debug(op + " conflict")
+		return current, &YError{Op: op, Path: p.ToString(), msg: "timestamp mismatch", kind: ErrConflict}
+	}
+	return nil, w.write(ctx, op, p, value.Encode(), value.Encoding(), PUT)
+}
+
+// currentEntry returns the current Entry of the absolute path p (nil if none), retrieved with a Get
+func (w *Workspace) currentEntry(ctx context.Context, op string, p *Path) (*Entry, error) {
+	selector, err := NewSelector(p.ToString())
+	if err != nil {
+		return nil, &YError{Op: op, Path: p.ToString(), kind: ErrInvalidSelector, cause: err}
+	}
+	results, err := w.GetContext(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if *results[i].Path() == *p {
+			return &results[i], nil
+		}
+	}
+	return nil, nil
+}
+
 // entries: a list of Entry that can be sorted per Timestamp
 type entries []Entry
 
@@ -84,12 +563,28 @@ func (e entries) asSortedSet() entries {
 	return res
 }
 
+// latestPerSource returns the latest entry from each source (see Entry.SourceID)
+func (e entries) latestPerSource() entries {
+	latest := make(map[string]Entry)
+	for _, entry := range e {
+		key := entry.sourceKind.String() + "/" + entry.sourceID
+		if l, ok := latest[key]; !ok || l.tstamp.Before(entry.tstamp) {
+			latest[key] = entry
+		}
+	}
+	res := make([]Entry, 0, len(latest))
+	for _, entry := range latest {
+		res = append(res, entry)
+	}
+	return res
+}
+
 // isSelectorForSeries returns true if the selector implies time series within reply
 func isSelectorForSeries(selector *Selector) bool {
 	// search for starttime or stoptime property in selector
 	props := strings.Split(selector.Properties(), ";")
 	for _, p := range props {
-		if strings.HasPrefix(p, "starttime") || strings.HasPrefix(p, "stoptime") {
+		if strings.HasPrefix(p, PropStartTime) || strings.HasPrefix(p, PropStopTime) {
 			return true
 		}
 	}
@@ -97,174 +592,610 @@ func isSelectorForSeries(selector *Selector) bool {
 }
 
 // Get a selection of path/value from Yaks.
+// Notice that Get doesn't report the errors: if the Get fails, an empty result is returned.
+// Use GetContext (or GetWithReport) to be notified of the errors.
 func (w *Workspace) Get(selector *Selector) []Entry {
+	results, _ := w.GetContext(context.Background(), selector)
+	return results
+}
+
+// GetContext gets a selection of path/value from Yaks.
+// If ctx is done before all the replies have been received, it returns an error matching ctx.Err()
+// (and ErrTimeout if the deadline of ctx is exceeded) and the replies arriving later are ignored.
+// Likewise, if the session with Yaks is lost meanwhile, it returns an error matching ErrDisconnected.
+func (w *Workspace) GetContext(ctx context.Context, selector *Selector) ([]Entry, error) {
+	qresults, _, err := w.query(ctx, selector, nil)
+	if err != nil {
+		return nil, err
+	}
+	return consolidate(selector, qresults, ConsolidationAuto), nil
+}
+
+// ReplyFailure describes a reply to a Get that has been dropped
+type ReplyFailure struct {
+	// Path is the path of the reply (as received, possibly invalid)
+	Path string
+	// Encoding is the encoding of the reply
+	Encoding Encoding
+	// Cause is the reason why the reply was dropped (matching ErrInvalidPath, ErrUnknownEncoding or ErrDecode)
+	Cause error
+}
+
+// GetReport reports how the replies to a Get have been processed
+type GetReport struct {
+	// Failures lists the replies that have been dropped
+	Failures []ReplyFailure
+	// StorageSources is the number of storages that answered
+	StorageSources int
+	// EvalSources is the number of evals that answered
+	EvalSources int
+	// Partial is true if the Get returned while replies were still outstanding (see Quorum)
+	Partial bool
+	// Disagreements lists the paths for which the sources replied with different entries
+	Disagreements []Disagreement
+}
+
+// Disagreement describes a path for which the sources replied with different entries
+// (i.e. with different timestamps or values)
+type Disagreement struct {
+	Path *Path
+	// Entries are the latest entries of the path from each source
+	Entries []Entry
+}
+
+// GetWithReport gets a selection of path/value from Yaks, as Get does, but also returns a GetReport
+// listing the replies that couldn't be decoded and the number of sources that answered.
+func (w *Workspace) GetWithReport(selector *Selector) ([]Entry, *GetReport, error) {
+	return w.GetWithReportContext(context.Background(), selector)
+}
+
+// GetWithReportContext is the same as GetWithReport, with a context as for GetContext.
+func (w *Workspace) GetWithReportContext(ctx context.Context, selector *Selector) ([]Entry, *GetReport, error) {
+	qresults, report, err := w.query(ctx, selector, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Disagreements = disagreements(qresults)
+	return consolidate(selector, qresults, ConsolidationAuto), report, nil
+}
+
+// Consolidation is the way the replies to a Get are consolidated into the result
+type Consolidation uint8
+
+const (
+	// ConsolidationAuto is ConsolidationAll if the selector has a "starttime" or "stoptime" property,
+	// ConsolidationLatest otherwise. It's the default consolidation.
+	ConsolidationAuto Consolidation = iota
+	// ConsolidationLatest keeps only the latest Entry of each path
+	ConsolidationLatest
+	// ConsolidationAll keeps all the Entries of each path (i.e. a time series), sorted per Timestamp
+	// and without the duplicates (i.e. with the same Timestamp)
+	ConsolidationAll
+	// ConsolidationNone keeps all the replies, including the duplicates from several storages.
+	// As with the other consolidations, the entries are sorted by path, timestamp and source.
+	ConsolidationNone
+	// ConsolidationBySource keeps the latest Entry of each path from each source (see Entry.SourceID)
+	ConsolidationBySource
+)
+
+// GetOptions are the options of a Get with Workspace.GetWithOptions
+type GetOptions struct {
+	// Consolidation is the way the replies are consolidated (ConsolidationAuto by default).
+	Consolidation Consolidation
+	// HideExpired excludes from the result the entries with a value put by this Workspace with PutWithTTL
+	// that is expired, but not removed yet.
+	HideExpired bool
+	// Order is the order of the result (OrderByPath by default).
+	Order Order
+	// Cursor resumes a Get after the last Entry of a previous Page (obtained with the same Order).
+	Cursor string
+	// Offset is the number of entries to be skipped (after the Cursor, if any).
+	Offset int
+	// Limit is the maximum number of entries in the result (no limit if 0).
+	Limit int
+}
+
+// GetWithOptions gets a selection of path/value from Yaks, as Get does, applying the options.
+// options can be nil (i.e. the default options).
+func (w *Workspace) GetWithOptions(selector *Selector, options *GetOptions) ([]Entry, error) {
+	return w.GetWithOptionsContext(context.Background(), selector, options)
+}
+
+// GetWithOptionsContext is the same as GetWithOptions, with a context as for GetContext.
+func (w *Workspace) GetWithOptionsContext(ctx context.Context, selector *Selector, options *GetOptions) ([]Entry, error) {
+	page, err := w.GetPageContext(ctx, selector, options)
+	if err != nil {
+		return nil, err
+	}
+	return page.Entries, nil
+}
+
+// getWithOptions gets the entries matching selector, consolidated, filtered and sorted as per the options
+// (but without applying the Cursor, Offset and Limit).
+func (w *Workspace) getWithOptions(ctx context.Context, selector *Selector, options *GetOptions) ([]Entry, error) {
+	qresults, _, err := w.query(ctx, selector, nil)
+	if err != nil {
+		return nil, err
+	}
+	results := consolidate(selector, qresults, options.Consolidation)
+	if options.HideExpired {
+		now := time.Now()
+		kept := results[:0]
+		for _, e := range results {
+			if w.isExpired(&e, now) {
+				continue
+			}
+			kept = append(kept, e)
+		}
+		results = kept
+	}
+	if options.Order != OrderByPath {
+		sortEntries(results, options.Order)
+	}
+	return results, nil
+}
+
+// query sends a query for selector and collects all the replies, per path.
+// If quorum is not nil, the query finishes as soon as the quorum is reached.
+func (w *Workspace) query(ctx context.Context, selector *Selector, quorum *Quorum) (map[Path]entries, *GetReport, error) {
 	s := w.toAbsoluteSelector(selector)
 	logger := logger.WithField("selector", s)
 	logger.Debug("Get")
 
-	qresults := make(map[Path]entries)
-	queryFinished := false
+	if ctx.Err() != nil {
+		return nil, nil, contextError(ctx, "Get", s.ToString())
+	}
 
+	qresults := make(map[Path]entries)
+	report := new(GetReport)
+	queryFinished := make(chan struct{})
+	// closed when the quorum (if any) is reached
+	quorumReached := make(chan struct{})
+	reached := false
+	// set when the query is finished or abandoned: any later reply is ignored
+	closed := false
 	mu := new(sync.Mutex)
-	cond := sync.NewCond(mu)
 
-	replyCb := func(reply *zenoh.ReplyValue) {
-		switch reply.Kind() {
-		case zenoh.ZStorageData, zenoh.ZEvalData:
-			path, err := NewPath(reply.RName())
-			if err != nil {
-				logger.WithField("reply path", reply.RName()).
-					Warn("Get received reply for an invalid path")
-				return
-			}
-			data := reply.Data()
-			info := reply.Info()
-			encoding := info.Encoding()
-			ts := info.Tstamp()
-			if reply.Kind() == zenoh.ZStorageData {
+	replyCb := func(reply *Reply) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		switch reply.Kind {
+		case ReplyStorageData, ReplyEvalData:
+			if reply.Kind == ReplyStorageData {
 				logger.WithFields(log.Fields{
-					"reply path": reply.RName(),
-					"len(data)":  len(data),
-					"encoding":   encoding,
+					"reply path": reply.Path,
+					"len(data)":  len(reply.Data),
+					"encoding":   reply.Encoding,
 				}).Trace("Get => Z_STORAGE_DATA")
 			} else {
 				logger.WithFields(log.Fields{
-					"reply path": reply.RName(),
-					"len(data)":  len(data),
-					"encoding":   encoding,
+					"reply path": reply.Path,
+					"len(data)":  len(reply.Data),
+					"encoding":   reply.Encoding,
 				}).Trace("Get => Z_EVAL_DATA")
 			}
 
-			decoder, ok := valueDecoders[encoding]
-			if !ok {
-				logger.WithFields(log.Fields{
-					"reply path": reply.RName(),
-					"encoding":   encoding,
-				}).Warn("Get : no Decoder found for reply")
-				return
-			}
-			value, err := decoder(data)
+			entry, err := entryOfReply(logger, reply)
 			if err != nil {
-				logger.WithFields(log.Fields{
-					"reply path": reply.RName(),
-					"encoding":   encoding,
-					"error":      err,
-				}).Warn("Get : error decoding reply")
+				report.Failures = append(report.Failures, ReplyFailure{reply.Path, reply.Encoding, err})
 				return
 			}
-			entry := Entry{path, value, &ts}
-			l, _ := qresults[*path]
-			qresults[*path] = append(l, entry)
+			l, _ := qresults[*entry.path]
+			qresults[*entry.path] = append(l, entry)
 
-		case zenoh.ZStorageFinal:
-			logger.Trace("Get => Z_STORAGE_FINAL")
-
-		case zenoh.ZEvalFinal:
-			logger.Trace("Get => Z_EVAL_FINAL")
+		case ReplyStorageFinal, ReplyEvalFinal:
+			if reply.Kind == ReplyStorageFinal {
+				logger.Trace("Get => Z_STORAGE_FINAL")
+				report.StorageSources++
+			} else {
+				logger.Trace("Get => Z_EVAL_FINAL")
+				report.EvalSources++
+			}
+			if quorum != nil && !reached && quorum.reached(report.StorageSources+report.EvalSources, qresults) {
+				logger.WithField("sources", quorum.Sources).Debug("Get quorum reached")
+				reached = true
+				close(quorumReached)
+			}
 
-		case zenoh.ZReplyFinal:
+		case ReplyFinal:
 			logger.WithField("nb replies", len(qresults)).Trace("Get => Z_REPLY_FINAL")
-			queryFinished = true
-			mu.Lock()
-			defer mu.Unlock()
-			cond.Signal()
+			closed = true
+			close(queryFinished)
 		}
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-	w.zenoh.Query(s.Path(), s.OptionalPart(), replyCb)
-	for !queryFinished {
-		cond.Wait()
+	t, lost, err := w.querySession("Get", s.ToString())
+	if err != nil {
+		return nil, nil, err
 	}
+	if err := t.Query(s.Path(), s.OptionalPart(), replyCb); err != nil {
+		w.yaks.transportFailed(t, err)
+		return nil, nil, &YError{Op: "Get", Path: s.ToString(), cause: err}
+	}
+	var deadline <-chan time.Time
+	if quorum != nil && quorum.Deadline > 0 {
+		timer := time.NewTimer(quorum.Deadline)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	select {
+	case <-queryFinished:
+	case <-quorumReached:
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			report.Partial = true
+		}
+	case <-deadline:
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			logger.Debug("Get deadline reached before Z_REPLY_FINAL")
+			closed = true
+			report.Partial = true
+		}
+	case <-ctx.Done():
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			logger.Debug("Get abandoned before Z_REPLY_FINAL")
+			closed = true
+			return nil, nil, contextError(ctx, "Get", s.ToString())
+		}
+	case <-lost:
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			logger.Debug("Get interrupted by the loss of the session before Z_REPLY_FINAL")
+			closed = true
+			return nil, nil, w.yaks.lostError("Get", s.ToString())
+		}
+	}
+	return qresults, report, nil
+}
 
-	results := make([]Entry, 0)
-	if isSelectorForSeries(selector) {
-		// return all entries
-		for _, entries := range qresults {
-			entries = entries.asSortedSet()
-			for _, e := range entries {
-				results = append(results, e)
-			}
+// entryOfReply returns the Entry of a data Reply, or an error if it's invalid
+func entryOfReply(logger *log.Entry, reply *Reply) (Entry, error) {
+	path, err := NewPath(reply.Path)
+	if err != nil {
+		logger.WithField("reply path", reply.Path).
+			Warn("Get received reply for an invalid path")
+		return Entry{}, err
+	}
+	value, err := DecodeValue(reply.Encoding, reply.Data)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"reply path": reply.Path,
+			"encoding":   reply.Encoding,
+			"error":      err,
+		}).Warn("Get : error decoding reply")
+		return Entry{}, err
+	}
+	ts := reply.Timestamp
+	kind := SourceStorage
+	if reply.Kind == ReplyEvalData {
+		kind = SourceEval
+	}
+	return Entry{path, value, &ts, kind, hex.EncodeToString(reply.SourceID)}, nil
+}
+
+// consolidate returns the entries to be returned by a Get on selector, from the replies per path,
+// sorted by path (and by timestamp for each path)
+func consolidate(selector *Selector, qresults map[Path]entries, consolidation Consolidation) []Entry {
+	if consolidation == ConsolidationAuto {
+		if isSelectorForSeries(selector) {
+			consolidation = ConsolidationAll
+		} else {
+			consolidation = ConsolidationLatest
 		}
-	} else {
-		// return only the latest entry for each path
-		for _, entries := range qresults {
+	}
+	results := make([]Entry, 0)
+	for _, entries := range qresults {
+		switch consolidation {
+		case ConsolidationNone:
+			// return all entries, including the duplicates
+			results = append(results, entries...)
+		case ConsolidationAll:
+			// return all entries
+			results = append(results, entries.asSortedSet()...)
+		case ConsolidationBySource:
+			// return the latest entry from each source
+			results = append(results, entries.latestPerSource()...)
+		default:
+			// return only the latest entry for each path
 			entries = entries.asSortedSet()
-			e := entries[len(entries)-1]
-			results = append(results, e)
+			results = append(results, entries[len(entries)-1])
 		}
 	}
+	sortEntries(results, OrderByPath)
 	return results
 }
 
 // Subscribe subscribes to a selection of path/value from Yaks.
 func (w *Workspace) Subscribe(selector *Selector, listener Listener) (*SubscriptionID, error) {
-	s := w.toAbsoluteSelector(selector)
-	logger := logger.WithField("selector", s)
-	logger.Debug("Subscribe")
+	return w.SubscribeContext(context.Background(), selector, listener)
+}
 
-	zListener := func(rid string, data []byte, info *zenoh.DataInfo) {
-		var changes = make([]Change, 1)
-		var err error
-		changes[0].path, err = NewPath(rid)
-		if err != nil {
-			logger.WithField("notif path", rid).Warn("Subscribe received a notification for an invalid path")
-			return
+// SubscribeContext subscribes to a selection of path/value from Yaks.
+// If ctx is done before the subscription is declared, it returns an error matching ctx.Err() and the
+// subscription is withdrawn as soon as its declaration completes.
+// Once declared, the subscription is not bound to ctx anymore; use Unsubscribe to terminate it.
+func (w *Workspace) SubscribeContext(ctx context.Context, selector *Selector, listener Listener) (*SubscriptionID, error) {
+	return w.subscribe(ctx, selector, listener, nil)
+}
+
+// SubscribeMerged subscribes to a selection of path/value from Yaks, as Subscribe does, but the
+// UPDATE changes are notified with the result of their merge with the latest known value (see Merge):
+// Change.Value() returns the merged value, while Change.Delta() returns the update itself.
+// The latest values are initialized with a Get on the selector (the changes received meanwhile are
+// notified afterwards), and the changes with a Timestamp older than the latest known value of their
+// path are ignored.
+func (w *Workspace) SubscribeMerged(selector *Selector, listener Listener) (*SubscriptionID, error) {
+	return w.SubscribeMergedContext(context.Background(), selector, listener)
+}
+
+// SubscribeMergedContext is the same as SubscribeMerged, with a context as for SubscribeContext and GetContext.
+func (w *Workspace) SubscribeMergedContext(ctx context.Context, selector *Selector, listener Listener) (*SubscriptionID, error) {
+	cache := &mergeCache{values: make(map[Path]*mergedValue)}
+	subid, err := w.subscribe(ctx, selector, listener, cache)
+	if err != nil {
+		return nil, err
+	}
+	results, err := w.GetContext(ctx, selector)
+	if err != nil {
+		w.Unsubscribe(subid)
+		return nil, err
+	}
+	cache.init(results, func(changes []Change) {
+		w.runCallback(func() { listener(changes) })
+	})
+	return subid, nil
+}
+
+// mergeTombstoneRetention is the duration a mergeCache keeps the removed paths,
+// to ignore the changes older than the removal that would be received afterwards
+const mergeTombstoneRetention = time.Minute
+
+// mergeCache keeps the latest known value of each path for a subscription with SubscribeMerged
+type mergeCache struct {
+	mu          sync.Mutex
+	values      map[Path]*mergedValue
+	initialized bool
+	// changes received before the initialization, to be applied afterwards
+	pending []pendingChange
+	// time of the last purge of the tombstones
+	lastPurge time.Time
+}
+
+// mergedValue is a value kept in a mergeCache (a tombstone if value is nil)
+type mergedValue struct {
+	value Value
+	ts    Timestamp
+	// local time of the removal, for a tombstone
+	removed time.Time
+}
+
+// pendingChange is a change received by a mergeCache before its initialization
+type pendingChange struct {
+	change Change
+	ts     Timestamp
+}
+
+// init sets the values of the cache that are older than the entries, then applies the changes received
+// before, calling deliver (with the cache locked, so that it's not concurrent with later changes)
+// with those that must be notified.
+func (c *mergeCache) init(entries []Entry, deliver func(changes []Change)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		if cur, ok := c.values[*e.path]; !ok || cur.ts.Before(e.tstamp) {
+			c.values[*e.path] = &mergedValue{value: e.value, ts: *e.tstamp}
 		}
-		encoding := info.Encoding()
-		decoder, ok := valueDecoders[encoding]
-		if !ok {
-			logger.WithFields(log.Fields{
-				"notif path": rid,
-				"encoding":   encoding,
-			}).Warn("Subscribe received a notification with an encoding, but no Decoder found for it")
-			return
+	}
+	c.initialized = true
+	var changes []Change
+	for i := range c.pending {
+		if c.applyLocked(&c.pending[i].change, &c.pending[i].ts) {
+			changes = append(changes, c.pending[i].change)
 		}
-		changes[0].value, err = decoder(data)
+	}
+	c.pending = nil
+	if len(changes) > 0 {
+		deliver(changes)
+	}
+}
+
+// apply applies the change made at ts to the cache, setting its value to the merged value for an UPDATE.
+// It returns false if the change must be ignored, as older than the cached value or failing to be merged,
+// or if it's postponed until the initialization of the cache (see init).
+func (c *mergeCache) apply(change *Change, ts *Timestamp) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.initialized {
+		// an UPDATE can't be merged before the latest value is known
+		c.pending = append(c.pending, pendingChange{*change, *ts})
+		return false
+	}
+	return c.applyLocked(change, ts)
+}
+
+// applyLocked is the same as apply, with c.mu locked and c initialized
+func (c *mergeCache) applyLocked(change *Change, ts *Timestamp) bool {
+	c.purgeTombstones()
+	cur, ok := c.values[*change.path]
+	if ok && !cur.ts.Before(ts) {
+		return false
+	}
+	switch change.kind {
+	case REMOVE:
+		c.values[*change.path] = &mergedValue{ts: *ts, removed: time.Now()}
+	case UPDATE:
+		var base Value
+		if ok {
+			base = cur.value
+		}
+		merged, err := Merge(base, change.delta)
 		if err != nil {
 			logger.WithFields(log.Fields{
-				"notif path": rid,
-				"encoding":   encoding,
-				"error":      err,
-			}).Warn("Subscribe received a notification, but Decoder failed to decode")
-			return
+				"path":  change.path,
+				"error": err,
+			}).Warn("SubscribeMerged failed to merge an update: ignore it")
+			return false
 		}
+		change.value = merged
+		c.values[*change.path] = &mergedValue{value: merged, ts: *ts}
+	default:
+		c.values[*change.path] = &mergedValue{value: change.value, ts: *ts}
+	}
+	return true
+}
 
-		changes[0].kind = info.Kind()
-		ts := info.Tstamp()
-		changes[0].time = ts.Time()
+// purgeTombstones removes the tombstones older than mergeTombstoneRetention (at most once per retention period)
+func (c *mergeCache) purgeTombstones() {
+	now := time.Now()
+	if now.Sub(c.lastPurge) < mergeTombstoneRetention {
+		return
+	}
+	c.lastPurge = now
+	for path, v := range c.values {
+		if v.value == nil && now.Sub(v.removed) >= mergeTombstoneRetention {
+			delete(c.values, path)
+		}
+	}
+}
 
-		if w.useSubroutine {
-			go listener(changes)
-		} else {
-			listener(changes)
+// subscribe subscribes listener to selector, merging the updates with cache if not nil
+func (w *Workspace) subscribe(ctx context.Context, selector *Selector, listener Listener, cache *mergeCache) (*SubscriptionID, error) {
+	s := w.toAbsoluteSelector(selector)
+	logger := logger.WithField("selector", s)
+	logger.Debug("Subscribe")
+
+	// changes of the batches being received, per batch id
+	batches := make(map[string][]Change)
+	batchesReceived := make(map[string]int)
+	batchesMu := new(sync.Mutex)
+
+	sampleHandler := func(sample *Sample) {
+		change, ok := changeOfSample(logger, sample)
+		if ok && cache != nil {
+			ok = cache.apply(&change, &sample.Timestamp)
+		}
+		var changes []Change
+		if sample.BatchSize > 1 {
+			// deliver all the changes of the batch at once, when the last one is received
+			batchesMu.Lock()
+			if ok {
+				batches[sample.BatchID] = append(batches[sample.BatchID], change)
+			}
+			batchesReceived[sample.BatchID]++
+			if batchesReceived[sample.BatchID] < sample.BatchSize {
+				batchesMu.Unlock()
+				return
+			}
+			changes = batches[sample.BatchID]
+			delete(batches, sample.BatchID)
+			delete(batchesReceived, sample.BatchID)
+			batchesMu.Unlock()
+		} else if ok {
+			changes = []Change{change}
+		}
+		if len(changes) > 0 {
+			w.runCallback(func() { listener(changes) })
+		}
+	}
+
+	var subid *SubscriptionID
+	err := runWithContext(ctx, "Subscribe", s.ToString(), func() error {
+		t, err := w.connectedSession("Subscribe", s.ToString())
+		if err != nil {
+			return err
+		}
+		tsub, err := t.DeclareSubscriber(s.Path(), sampleHandler)
+		if err != nil {
+			w.yaks.transportFailed(t, err)
+			return &YError{Op: "Subscribe", Path: s.ToString(), cause: err}
 		}
+		subid = &SubscriptionID{s.ToString()}
+		w.mu.Lock()
+		w.subs[subid] = &subscription{s.Path(), sampleHandler, t, tsub}
+		w.mu.Unlock()
+		return nil
+	}, func() {
+		logger.Debug("Subscribe abandoned: undeclare late subscriber")
+		w.Unsubscribe(subid)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return subid, nil
+}
 
-	sub, err := w.zenoh.DeclareSubscriber(s.Path(), zenoh.NewSubMode(zenoh.ZPushMode), zListener)
+// changeOfSample returns the Change notified by a Sample, or false if the Sample is invalid
+func changeOfSample(logger *log.Entry, sample *Sample) (Change, bool) {
+	path, err := NewPath(sample.Path)
 	if err != nil {
-		return nil, &YError{"Subscribe on " + s.ToString() + " failed", err}
+		logger.WithField("notif path", sample.Path).Warn("Subscribe received a notification for an invalid path")
+		return Change{}, false
 	}
-	return sub, nil
+	value, err := DecodeValue(sample.Encoding, sample.Data)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"notif path": sample.Path,
+			"encoding":   sample.Encoding,
+			"error":      err,
+		}).Warn("Subscribe received a notification, but failed to decode it")
+		return Change{}, false
+	}
+	return Change{path, sample.Kind, sample.Timestamp.Time(), value, value, sample.BatchID}, true
 }
 
 // Unsubscribe unregisters a previous subscription
 func (w *Workspace) Unsubscribe(subid *SubscriptionID) error {
-	err := w.zenoh.UndeclareSubscriber(subid)
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return closedError("Unsubscribe", subid.selector)
+	}
+	sub, ok := w.subs[subid]
+	delete(w.subs, subid)
+	if !ok {
+		w.mu.Unlock()
+		return &YError{Op: "Unsubscribe", Path: subid.selector, msg: "unknown subscription", kind: ErrNotFound}
+	}
+	undeclare := w.undeclarable(sub.t, pendingUndeclare{t: sub.t, tsub: sub.tsub})
+	w.mu.Unlock()
+	if !undeclare {
+		// the subscription won't be re-declared (or will be undeclared once re-declared)
+		return nil
+	}
+	t := sub.t
+	err := t.UndeclareSubscriber(sub.tsub)
 	if err != nil {
-		return &YError{"Unsubscribe failed", err}
+		w.yaks.transportFailed(t, err)
+		return &YError{Op: "Unsubscribe", Path: sub.resource, cause: err}
 	}
 	return nil
 }
 
 // RegisterEval registers an evaluation function with a Path
 func (w *Workspace) RegisterEval(path *Path, eval Eval) error {
+	return w.RegisterEvalContext(context.Background(), path, eval)
+}
+
+// RegisterEvalContext registers an evaluation function with a Path.
+// If ctx is done before the eval is declared, it returns an error matching ctx.Err() and the
+// eval is withdrawn as soon as its declaration completes.
+// Once declared, the eval is not bound to ctx anymore; use UnregisterEval to terminate it.
+func (w *Workspace) RegisterEvalContext(ctx context.Context, path *Path, eval Eval) error {
 	p := w.toAbsolutePath(path)
 	logger := logger.WithField("path", p)
 	logger.Debug("RegisterEval")
 
-	zQueryHandler := func(rname string, predicate string, repliesSender *zenoh.RepliesSender) {
+	queryHandler := func(rname string, predicate string, sendReplies RepliesSender) {
 		logger.WithFields(log.Fields{
 			"rname":     rname,
 			"predicate": predicate,
@@ -272,6 +1203,7 @@ func (w *Workspace) RegisterEval(path *Path, eval Eval) error {
 		s, err := NewSelector(rname + "?" + predicate)
 		if err != nil {
 			logger.WithField("selector", s).Warn("Registered eval received query for an invalid selector")
+			sendReplies(nil)
 			return
 		}
 
@@ -282,37 +1214,71 @@ func (w *Workspace) RegisterEval(path *Path, eval Eval) error {
 				"predicate": predicate,
 				"value":     v,
 			}).Debug("Registered eval handling query returns")
-			replies := make([]zenoh.Resource, 1)
-			replies[0].RName = path.ToString()
+			replies := make([]Sample, 1)
+			replies[0].Path = path.ToString()
 			replies[0].Data = v.Encode()
 			replies[0].Encoding = v.Encoding()
 			replies[0].Kind = PUT
-			repliesSender.SendReplies(replies)
+			sendReplies(replies)
 		}
-		if w.useSubroutine {
-			go evalRoutine()
-		} else {
-			evalRoutine()
+		if !w.runCallback(evalRoutine) {
+			// the Workspace is closed: the replies must be sent anyway
+			sendReplies(nil)
 		}
 	}
 
-	e, err := w.zenoh.DeclareEval(p.ToString(), zQueryHandler)
-	if err != nil {
-		return &YError{"RegisterEval on " + p.ToString() + " failed", err}
-	}
-	w.evals[*p] = e
-	return nil
+	var e *registeredEval
+	return runWithContext(ctx, "RegisterEval", p.ToString(), func() error {
+		t, err := w.connectedSession("RegisterEval", p.ToString())
+		if err != nil {
+			return err
+		}
+		teval, err := t.DeclareEval(p.ToString(), queryHandler)
+		if err != nil {
+			w.yaks.transportFailed(t, err)
+			return &YError{Op: "RegisterEval", Path: p.ToString(), cause: err}
+		}
+		e = &registeredEval{p.ToString(), queryHandler, t, teval}
+		w.mu.Lock()
+		w.evals[*p] = e
+		w.mu.Unlock()
+		return nil
+	}, func() {
+		logger.Debug("RegisterEval abandoned: undeclare late eval")
+		w.mu.Lock()
+		if w.evals[*p] == e {
+			delete(w.evals, *p)
+		}
+		w.mu.Unlock()
+		e.t.UndeclareEval(e.teval)
+	})
 }
 
 // UnregisterEval requests the evaluation of registered evals whose registration path matches the given selector
 func (w *Workspace) UnregisterEval(path *Path) error {
-	e, ok := w.evals[*path]
-	if ok {
-		delete(w.evals, *path)
-		err := w.zenoh.UndeclareEval(e)
-		if err != nil {
-			return &YError{"UnregisterEval on " + path.ToString() + " failed", err}
-		}
+	p := w.toAbsolutePath(path)
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return closedError("UnregisterEval", p.ToString())
+	}
+	e, ok := w.evals[*p]
+	delete(w.evals, *p)
+	if !ok {
+		w.mu.Unlock()
+		return &YError{Op: "UnregisterEval", Path: p.ToString(), msg: "no eval registered", kind: ErrNotFound}
+	}
+	undeclare := w.undeclarable(e.t, pendingUndeclare{t: e.t, teval: e.teval})
+	w.mu.Unlock()
+	if !undeclare {
+		// the eval won't be re-declared (or will be undeclared once re-declared)
+		return nil
+	}
+	t := e.t
+	err := t.UndeclareEval(e.teval)
+	if err != nil {
+		w.yaks.transportFailed(t, err)
+		return &YError{Op: "UnregisterEval", Path: p.ToString(), cause: err}
 	}
 	return nil
 }
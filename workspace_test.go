@@ -0,0 +1,318 @@
+package yaks_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/yakstest"
+)
+
+func selector(t *testing.T, s string) *yaks.Selector {
+	t.Helper()
+	sel, err := yaks.NewSelector(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sel
+}
+
+func path(t *testing.T, s string) *yaks.Path {
+	t.Helper()
+	p, err := yaks.NewPath(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// changes collects the changes notified to a Listener
+type changes struct {
+	mu       sync.Mutex
+	received [][]yaks.Change
+	notified chan struct{}
+}
+
+func newChanges() *changes {
+	return &changes{notified: make(chan struct{}, 100)}
+}
+
+func (c *changes) listener(cs []yaks.Change) {
+	c.mu.Lock()
+	c.received = append(c.received, cs)
+	c.mu.Unlock()
+	c.notified <- struct{}{}
+}
+
+// await waits for n notifications and returns all the notifications received
+func (c *changes) await(t *testing.T, n int) [][]yaks.Change {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.notified:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d notifications received, want %d", i, n)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]yaks.Change(nil), c.received...)
+}
+
+func TestRemoveAllOncePerPath(t *testing.T) {
+	for _, batchSize := range []int{0, 10} {
+		s := yakstest.New(t)
+		s.SetStorage("st", "/a/**", map[string]yaks.Value{
+			"/a/x": yaks.NewStringValue("1"),
+			"/a/y": yaks.NewStringValue("2"),
+		})
+		// the eval gives a second entry for /a/x in the time series
+		s.ScriptEval("/a/x", yaks.NewStringValue("3"))
+		w := s.Workspace("/")
+		series, err := yaks.NewSelectorBuilder(selector(t, "/a/**")).StartTime(time.Unix(0, 1)).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := w.RemoveAll(series, &yaks.RemoveAllOptions{BatchSize: batchSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Path.ToString() != "/a/x" || results[1].Path.ToString() != "/a/y" {
+			t.Errorf("RemoveAll (batch size %d) results = %v, want /a/x and /a/y", batchSize, results)
+		}
+		removes := make(map[string]int)
+		for _, op := range s.Ops() {
+			if op.Kind == yakstest.OpRemove {
+				removes[op.Path]++
+			}
+		}
+		if removes["/a/x"] != 1 || removes["/a/y"] != 1 || len(removes) != 2 {
+			t.Errorf("RemoveAll (batch size %d) removes = %v, want each path once", batchSize, removes)
+		}
+		s.Close()
+	}
+}
+
+// unknownValue is a Value with an encoding without ValueDecoder
+type unknownValue struct{}
+
+func (unknownValue) Encoding() yaks.Encoding { return 0x7f }
+func (unknownValue) Encode() []byte          { return []byte("?") }
+func (unknownValue) ToString() string        { return "?" }
+
+func TestGetWithReport(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st1", "/a/**", map[string]yaks.Value{
+		"/a/x": yaks.NewStringValue("1"),
+		"/a/u": unknownValue{},
+	})
+	s.SetStorage("st2", "/a/x", nil)
+	s.ScriptEval("/a/e", yaks.NewStringValue("e"))
+	w := s.Workspace("/")
+
+	entries, report, err := w.GetWithReport(selector(t, "/a/**"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Path().ToString() != "/a/e" || entries[1].Path().ToString() != "/a/x" {
+		t.Errorf("entries = %v, want /a/e and /a/x", entries)
+	}
+	if report.StorageSources != 2 || report.EvalSources != 1 || report.Partial {
+		t.Errorf("report = %+v, want 2 storages and 1 eval", report)
+	}
+	if len(report.Failures) != 1 || report.Failures[0].Path != "/a/u" || !errors.Is(report.Failures[0].Cause, yaks.ErrUnknownEncoding) {
+		t.Errorf("failures = %+v, want the unknown encoding of /a/u", report.Failures)
+	}
+}
+
+func TestGetWithNilOptions(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", map[string]yaks.Value{"/a/x": yaks.NewStringValue("1")})
+	w := s.Workspace("/")
+	entries, err := w.GetWithOptions(selector(t, "/a/*"), nil)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("GetWithOptions(nil) = %v, %v", entries, err)
+	}
+	page, err := w.GetPage(selector(t, "/a/*"), nil)
+	if err != nil || len(page.Entries) != 1 || page.Cursor != "" {
+		t.Errorf("GetPage(nil) = %+v, %v", page, err)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", map[string]yaks.Value{
+		"/a/x": yaks.NewStringValue("1"),
+		"/a/y": yaks.NewStringValue("2"),
+	})
+	// the eval replies with a newer value of /a/x
+	s.ScriptEval("/a/x", yaks.NewStringValue("3"))
+	w := s.Workspace("/")
+	from := time.Now().Add(-time.Hour)
+	series, err := w.GetRange(selector(t, "/a/*"), from, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := series[*path(t, "/a/x")]
+	y := series[*path(t, "/a/y")]
+	if len(series) != 2 || len(x) != 2 || len(y) != 1 {
+		t.Fatalf("series = %v, want 2 values of /a/x and 1 of /a/y", series)
+	}
+	if x[0].Value().ToString() != "1" || x[1].Value().ToString() != "3" || y[0].Value().ToString() != "2" {
+		t.Errorf("series = %v, want the values of each path sorted per Timestamp", series)
+	}
+	want := "(starttime=" + from.UTC().Format(time.RFC3339Nano) + ")"
+	for _, op := range s.Ops() {
+		if op.Kind == yakstest.OpQuery && op.Predicate != want {
+			t.Errorf("query predicate = %q, want %q", op.Predicate, want)
+		}
+	}
+}
+
+func TestGetPage(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", map[string]yaks.Value{
+		"/a/1": yaks.NewStringValue("1"),
+		"/a/2": yaks.NewStringValue("2"),
+		"/a/3": yaks.NewStringValue("3"),
+		"/a/4": yaks.NewStringValue("4"),
+		"/a/5": yaks.NewStringValue("5"),
+	})
+	w := s.Workspace("/")
+	options := &yaks.GetOptions{Limit: 2}
+	var got []string
+	for i := 0; i < 10; i++ {
+		page, err := w.GetPage(selector(t, "/a/*"), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range page.Entries {
+			got = append(got, e.Value().ToString())
+		}
+		if page.Cursor == "" {
+			break
+		}
+		options.Cursor = page.Cursor
+	}
+	if len(got) != 5 || got[0] != "1" || got[4] != "5" {
+		t.Errorf("paged values = %v, want 1 to 5", got)
+	}
+	options = &yaks.GetOptions{Cursor: "not a cursor"}
+	if _, err := w.GetPage(selector(t, "/a/*"), options); err == nil {
+		t.Error("GetPage with an invalid cursor succeeded")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	w := s.Workspace("/")
+	c := newChanges()
+	subid, err := w.Subscribe(selector(t, "/a/*"), c.listener)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Put(path(t, "/a/x"), yaks.NewStringValue("1"))
+	w.Put(path(t, "/b/x"), yaks.NewStringValue("2"))
+	received := c.await(t, 1)
+	if len(received[0]) != 1 || received[0][0].Path().ToString() != "/a/x" || received[0][0].Kind() != yaks.PUT {
+		t.Errorf("first notification = %+v", received[0])
+	}
+
+	if err := w.Unsubscribe(subid); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Unsubscribe(subid); !errors.Is(err, yaks.ErrNotFound) {
+		t.Errorf("second Unsubscribe: %v, want ErrNotFound", err)
+	}
+}
+
+func TestRegisterEval(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	w := s.Workspace("/")
+	err := w.RegisterEval(path(t, "/e/hello"), func(p *yaks.Path, props yaks.Properties) yaks.Value {
+		return yaks.NewStringValue("hello " + props["name"])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := w.GetWithOptions(selector(t, "/e/hello?(name=bob)"), nil)
+	if err != nil || len(entries) != 1 || entries[0].Value().ToString() != "hello bob" {
+		t.Errorf("eval replied %v, %v", entries, err)
+	}
+	if err := w.UnregisterEval(path(t, "/e/hello")); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := w.GetWithOptions(selector(t, "/e/hello"), nil); len(entries) != 0 {
+		t.Errorf("unregistered eval replied %v", entries)
+	}
+}
+
+func TestWorkspaceClose(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	w := s.Workspace("/")
+	c := newChanges()
+	if _, err := w.Subscribe(selector(t, "/a/*"), c.listener); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if err := w.Put(path(t, "/a/x"), yaks.NewStringValue("1")); !errors.Is(err, yaks.ErrClosed) {
+		t.Errorf("Put on a closed Workspace: %v, want ErrClosed", err)
+	}
+	if _, err := w.GetWithOptions(selector(t, "/a/*"), nil); !errors.Is(err, yaks.ErrClosed) {
+		t.Errorf("Get on a closed Workspace: %v, want ErrClosed", err)
+	}
+	// the subscriber is undeclared
+	s.Workspace("/").Put(path(t, "/a/x"), yaks.NewStringValue("1"))
+	select {
+	case <-c.notified:
+		t.Error("change notified to the subscriber of a closed Workspace")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClosedWorkspaceErrors(t *testing.T) {
+	s := yakstest.New(t)
+	defer s.Close()
+	w := s.Workspace("/")
+	subid, err := w.Subscribe(selector(t, "/a/x"), func([]yaks.Change) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	check := func(err error, op string, path string) {
+		t.Helper()
+		var yerr *yaks.YError
+		if !errors.Is(err, yaks.ErrClosed) || !errors.As(err, &yerr) || yerr.Op != op || yerr.Path != path {
+			t.Errorf("%s on a closed Workspace: %#v, want a YError on %s matching ErrClosed", op, err, path)
+		}
+	}
+	check(w.Put(path(t, "/a/x"), yaks.NewStringValue("1")), "Put", "/a/x")
+	check(w.Remove(path(t, "/a/x")), "Remove", "/a/x")
+	_, err = w.GetWithOptions(selector(t, "/a/x"), nil)
+	check(err, "Get", "/a/x")
+	_, err = w.GetStream(selector(t, "/a/x"))
+	check(err, "GetStream", "/a/x")
+	_, err = w.Subscribe(selector(t, "/a/x"), func([]yaks.Change) {})
+	check(err, "Subscribe", "/a/x")
+	check(w.Unsubscribe(subid), "Unsubscribe", "/a/x")
+	check(w.RegisterEval(path(t, "/a/e"), nil), "RegisterEval", "/a/e")
+	check(w.UnregisterEval(path(t, "/a/e")), "UnregisterEval", "/a/e")
+	_, err = w.PutBatch(yaks.NewBatch().Put(path(t, "/a/x"), yaks.NewStringValue("1")))
+	check(err, "PutBatch", "")
+}
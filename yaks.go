@@ -1,10 +1,15 @@
 // Package yaks provides the Yaks client API in Go.
+//
+// It doesn't depend on the Zenoh transport, which requires cgo and the zenoh-c library: the Zenoh transport
+// is provided by the zenohtransport package, that must be imported for Login to establish the sessions via Zenoh.
+// LoginWithDialer and NewWithTransport allow to use another Transport (e.g. the in-process engine of the
+// memory package).
 package yaks
 
 import (
-	"encoding/hex"
-
-	"github.com/atolab/zenoh-go"
+	"errors"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -17,80 +22,550 @@ const PropPassword = "password"
 
 // Yaks is Yaks
 type Yaks struct {
-	zenoh  *zenoh.Zenoh
-	yaksid string
-	admin  *Admin
+	mu           sync.RWMutex
+	transport    Transport
+	dial         dialer
+	locators     []string
+	locatorIdx   int
+	user         string
+	retry        RetryPolicy
+	yaksid       string
+	admin        *Admin
+	workspaces   []*Workspace
+	executor     bool
+	maxInFlight  int
+	state        State
+	connListener ConnectionListener
+	stateObs     []StateObserver
+	closedInfo   *SessionInfo
+	// closed when the current Transport is lost (or closed by Logout)
+	lost chan struct{}
+	// closed by Logout
+	loggedOut chan struct{}
+}
+
+// State is the state of the session with Yaks
+type State uint8
+
+const (
+	// StateConnected means the session with Yaks is established
+	StateConnected State = iota
+	// StateReconnecting means the session with Yaks has been lost and is being re-established
+	StateReconnecting
+	// StateClosed means the session with Yaks has been terminated by Logout
+	StateClosed
+	// StateDisconnected means the session with Yaks has been lost and won't be re-established,
+	// as it was not established via a Dialer (see NewWithTransport). Only Logout remains to be called.
+	StateDisconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// StateObserver defines the callback function that can be registered to be notified of the state changes
+type StateObserver func(old State, new State)
+
+// NotConnectedError is the cause of the errors returned by the operations that cannot be performed
+// because the session with Yaks is not in StateConnected (and not closed by Logout): those errors are
+// YErrors matching ErrDisconnected, from which errors.As() retrieves the NotConnectedError.
+// It matches ErrDisconnected with errors.Is() too.
+type NotConnectedError struct {
+	State State
+}
+
+func (e *NotConnectedError) Error() string {
+	return "Not connected to Yaks (session is " + e.State.String() + ")"
 }
 
+// Is returns true if target is ErrDisconnected
+func (e *NotConnectedError) Is(target error) bool {
+	return target == ErrDisconnected
+}
+
+// ConnectionListener defines the callback function that can be registered to be notified
+// of the connection changes. It's called with connected=false and the error that revealed
+// the loss when the session with Yaks is lost, and with connected=true when it's re-established.
+type ConnectionListener func(connected bool, err error)
+
+// Sentinel errors, that can be tested with errors.Is() against the errors returned by Yaks.
+var (
+	// ErrInvalidPath reports an invalid Path specification
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrInvalidSelector reports an invalid Selector specification
+	ErrInvalidSelector = errors.New("invalid selector")
+	// ErrTimeout reports an operation that didn't complete in time
+	ErrTimeout = errors.New("timeout")
+	// ErrClosed reports an operation performed after Logout
+	ErrClosed = errors.New("session with Yaks is closed")
+	// ErrDisconnected reports an operation that failed because the session with Yaks is lost
+	// (e.g. a NotConnectedError while reconnecting)
+	ErrDisconnected = errors.New("session with Yaks is disconnected")
+	// ErrDecode reports a Value that failed to be decoded
+	ErrDecode = errors.New("decoding failed")
+	// ErrUnknownEncoding reports a Value with an Encoding for which no ValueDecoder is registered
+	ErrUnknownEncoding = errors.New("unknown encoding")
+	// ErrNotFound reports an operation on something that doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict reports a conditional write whose condition doesn't match the current state
+	ErrConflict = errors.New("conflict")
+	// ErrUnsupported reports an operation that is not supported by the Transport (e.g. PutBatch with a Transport without batches)
+	ErrUnsupported = errors.New("unsupported operation")
+)
+
 // YError reports an error that occurred in Yaks, possibly caused by an error in Zenoh.
+// It matches with errors.Is() the sentinel error qualifying it (if any), and its cause is
+// available via errors.Unwrap().
 type YError struct {
+	// Op is the operation that failed (e.g. "Put", "Get"...), if any
+	Op string
+	// Path is the Path or Selector concerned by the failed operation, if any
+	Path  string
 	msg   string
+	kind  error
 	cause error
 }
 
 func (e *YError) Error() string {
+	s := e.msg
+	if e.kind != nil {
+		if len(s) > 0 {
+			s = e.kind.Error() + " (" + s + ")"
+		} else {
+			s = e.kind.Error()
+		}
+	}
+	if len(e.Op) > 0 {
+		prefix := e.Op
+		if len(e.Path) > 0 {
+			prefix += " on " + e.Path
+		}
+		prefix += " failed"
+		if len(s) > 0 {
+			s = prefix + ": " + s
+		} else {
+			s = prefix
+		}
+	}
 	if e.cause != nil {
-		return e.msg + " - caused by:" + e.cause.Error()
+		return s + " - caused by:" + e.cause.Error()
 	}
-	return e.msg
+	return s
+}
+
+// Unwrap returns the cause of the error (possibly nil)
+func (e *YError) Unwrap() error {
+	return e.cause
+}
+
+// Is returns true if target is the sentinel error qualifying this error
+func (e *YError) Is(target error) bool {
+	return e.kind != nil && e.kind == target
 }
 
 var logger = log.WithFields(log.Fields{" pkg": "yaks"})
 
-func newYaks(z *zenoh.Zenoh) (*Yaks, error) {
-	props := z.Info()
-	pid, ok := props[zenoh.ZInfoPeerPidKey]
-	if !ok {
-		return nil, &YError{"Failed to retrieve YaksId from Zenoh info", nil}
+// dialer establishes a new Transport, trying the locators from index start.
+// It returns the Transport and the index of the locator used.
+type dialer func(start int) (Transport, int, error)
+
+func getYaksID(t Transport) (string, error) {
+	yaksid := t.Info().YaksID
+	if len(yaksid) == 0 {
+		return "", &YError{msg: "failed to retrieve YaksId from Transport info", kind: ErrNotFound}
 	}
-	yaksid := hex.EncodeToString(pid)
-	adminPath, _ := NewPath("/@")
-	adminWS := &Workspace{adminPath, z, make(map[Path]*zenoh.Eval), false}
-	return &Yaks{z, yaksid, &Admin{adminWS, yaksid}}, nil
+	return yaksid, nil
 }
 
-func getZProps(properties Properties) map[int][]byte {
-	zprops := make(map[int][]byte)
-	user, ok := properties[PropUser]
-	if ok {
-		zprops[zenoh.ZUserKey] = []byte(user)
+func newYaks(t Transport, dial dialer, locatorIdx int, options *LoginOptions) (*Yaks, error) {
+	yaksid, err := getYaksID(t)
+	if err != nil {
+		return nil, err
+	}
+	y := &Yaks{
+		transport:   t,
+		dial:        dial,
+		locators:    options.Locators,
+		locatorIdx:  locatorIdx,
+		user:        options.Properties[PropUser],
+		retry:       options.Retry,
+		yaksid:      yaksid,
+		maxInFlight: DefaultMaxInFlight,
+		state:       StateConnected,
+		lost:        make(chan struct{}),
+		loggedOut:   make(chan struct{}),
 	}
-	password, ok := properties[PropPassword]
-	if ok {
-		zprops[zenoh.ZPasswdKey] = []byte(password)
+	adminPath, _ := NewPath("/@")
+	y.admin = &Admin{y.newWorkspace(adminPath, false)}
+	keepAlive := options.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	if dial != nil && keepAlive > 0 {
+		go y.keepAlive(keepAlive)
 	}
-	return zprops
+	return y, nil
+}
+
+// NewWithTransport returns a Yaks using the provided Transport, instead of establishing a Zenoh session.
+// The Transport's Info() must return a SessionInfo with a YaksID.
+// Notice that if the Transport fails, no reconnection is attempted: the session goes to StateDisconnected.
+func NewWithTransport(t Transport) (*Yaks, error) {
+	return newYaks(t, nil, -1, &LoginOptions{Retry: DefaultRetryPolicy})
 }
 
-// Login establishes a session with the Yaks instance reachable via provided Zenoh locator.
-// If the provided locator is nil, 'login' will perform some dynamic discovery and try to
-// establish the session automatically. When not nil, the locator must have the format:
-// ``tcp/<ip>:<port>``.
-// Properties contains the configuration to be used for this session (e.g. "user", "password"...). It can be nil.
-func Login(locator *string, properties Properties) (*Yaks, error) {
-	logger.WithField("locator", locator).Debug("Connecting to Yaks via Zenoh")
-	z, e := zenoh.ZOpen(locator, getZProps(properties))
-	if e != nil {
-		return nil, &YError{"Login failed", e}
+// Locator returns the locator used by the current session with Yaks,
+// or an empty string if the session was established via dynamic discovery.
+func (y *Yaks) Locator() string {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+	if y.locatorIdx < 0 {
+		return ""
 	}
-	return newYaks(z)
+	return y.locators[y.locatorIdx]
 }
 
+// DefaultLogoutTimeout is the maximum duration Logout waits for the running callbacks to complete.
+const DefaultLogoutTimeout = 5 * time.Second
+
 // Logout terminates the session with Yaks.
+// See LogoutWithTimeout, called with DefaultLogoutTimeout.
 func (y *Yaks) Logout() error {
-	if e := y.zenoh.Close(); e != nil {
-		return &YError{"Error during logout", e}
+	return y.LogoutWithTimeout(DefaultLogoutTimeout)
+}
+
+// LogoutWithTimeout terminates the session with Yaks.
+// All the subscriptions and evals declared in the Workspaces created by this Yaks are undeclared,
+// and the subscription listeners and eval callbacks that are running are awaited until the timeout expires.
+// Afterwards, all the operations on those Workspaces return ErrClosed.
+// Notice that calling this operation from a listener or a callback makes it wait until the timeout expires.
+func (y *Yaks) LogoutWithTimeout(timeout time.Duration) error {
+	y.mu.Lock()
+	old := y.state
+	if old != StateClosed {
+		// keep the info of the session, as the Transport can't be used after its closure
+		y.closedInfo = y.transport.Info()
+	}
+	if old == StateConnected {
+		close(y.lost)
+	}
+	if old != StateClosed {
+		close(y.loggedOut)
+	}
+	y.state = StateClosed
+	t := y.transport
+	workspaces := y.workspaces
+	y.workspaces = nil
+	y.mu.Unlock()
+	if old == StateClosed {
+		return nil
+	}
+	y.notifyState(old, StateClosed, nil)
+
+	var result error
+	deadline := time.Now().Add(timeout)
+	for _, w := range workspaces {
+		if err := w.close(t, deadline); err != nil && result == nil {
+			result = err
+		}
+	}
+	if e := t.Close(); e != nil {
+		if old == StateDisconnected {
+			// the Transport already failed
+			logger.WithField("error", e).Debug("Failed to close the lost Transport")
+			return result
+		}
+		return &YError{Op: "Logout", cause: e}
+	}
+	return result
+}
+
+// SetConnectionListener registers a ConnectionListener that will be called each time the session
+// with Yaks is lost or re-established. When lost, the session is automatically re-established
+// using the locators and properties given at login (unless created with NewWithTransport), and all the subscriptions and evals declared
+// in the Workspaces are declared again.
+func (y *Yaks) SetConnectionListener(listener ConnectionListener) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	y.connListener = listener
+}
+
+// State returns the current state of the session with Yaks.
+func (y *Yaks) State() State {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+	return y.state
+}
+
+// OnStateChange registers a StateObserver that will be called on each change of the session's state.
+func (y *Yaks) OnStateChange(observer StateObserver) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	y.stateObs = append(y.stateObs, observer)
+}
+
+func (y *Yaks) notifyState(old State, new State, cause error) {
+	logger.WithFields(log.Fields{
+		"old": old,
+		"new": new,
+	}).Debug("Session state changed")
+	y.mu.RLock()
+	observers := y.stateObs
+	listener := y.connListener
+	y.mu.RUnlock()
+	for _, o := range observers {
+		o(old, new)
+	}
+	if listener != nil {
+		if new == StateReconnecting || new == StateDisconnected {
+			listener(false, cause)
+		} else if new == StateConnected {
+			listener(true, nil)
+		}
+	}
+}
+
+// sessionState returns the current Transport and the state of the session
+func (y *Yaks) sessionState() (Transport, State) {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+	return y.transport, y.state
+}
+
+// connectedSession returns the current Transport to perform op on path, or an error matching ErrClosed
+// or ErrDisconnected (caused by a NotConnectedError) if not in StateConnected.
+func (y *Yaks) connectedSession(op string, path string) (Transport, error) {
+	t, _, err := y.querySession(op, path)
+	return t, err
+}
+
+// querySession returns the current Transport to perform op on path and a channel closed when it's lost,
+// to wait for replies, or an error matching ErrClosed or ErrDisconnected if not in StateConnected.
+func (y *Yaks) querySession(op string, path string) (Transport, <-chan struct{}, error) {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+	if y.state == StateClosed {
+		return nil, nil, closedError(op, path)
+	}
+	if y.state != StateConnected {
+		return nil, nil, &YError{Op: op, Path: path, kind: ErrDisconnected, cause: &NotConnectedError{y.state}}
+	}
+	return y.transport, y.lost, nil
+}
+
+// closedError returns the error of the operation op on path, performed after Logout or Workspace.Close
+func closedError(op string, path string) error {
+	return &YError{Op: op, Path: path, kind: ErrClosed}
+}
+
+// lostError returns the error of the operation op on path, interrupted by the loss of the Transport:
+// it matches ErrClosed after Logout, ErrDisconnected otherwise.
+func (y *Yaks) lostError(op string, path string) error {
+	if y.State() == StateClosed {
+		return &YError{Op: op, Path: path, msg: "interrupted by Logout", kind: ErrClosed}
+	}
+	return &YError{Op: op, Path: path, msg: "session with Yaks lost", kind: ErrDisconnected}
+}
+
+// SessionInfo contains the information about a session with Yaks
+type SessionInfo struct {
+	// SessionID is the id of the local session
+	SessionID string
+	// YaksID is the id of the Yaks the session is connected to
+	YaksID string
+	// Locator is the locator of the Yaks the session is connected to, as reported by the Transport
+	Locator string
+	// User is the user authenticated in the session (empty if none)
+	User string
+}
+
+// Info returns the information about the current session with Yaks
+// (or about the last session, after Logout).
+func (y *Yaks) Info() *SessionInfo {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+	var info *SessionInfo
+	if y.state == StateClosed {
+		cached := *y.closedInfo
+		info = &cached
+	} else {
+		// called with the lock held, so that Logout can't close the Transport meanwhile
+		info = y.transport.Info()
+	}
+	info.User = y.user
+	return info
+}
+
+// getYaksid returns the id of the Yaks currently connected
+func (y *Yaks) getYaksid() string {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+	return y.yaksid
+}
+
+// transportFailed must be called when an operation on the Transport t failed.
+// The failure is considered as a lost session and a reconnection is started (if not already running),
+// or, without Dialer, the session goes to StateDisconnected.
+func (y *Yaks) transportFailed(t Transport, cause error) {
+	y.mu.Lock()
+	if y.state != StateConnected || y.transport != t {
+		y.mu.Unlock()
+		return
+	}
+	if y.dial == nil {
+		logger.WithField("error", cause).Warn("Session with Yaks lost")
+		y.state = StateDisconnected
+		close(y.lost)
+		y.mu.Unlock()
+		y.notifyState(StateConnected, StateDisconnected, cause)
+		return
+	}
+	logger.WithField("error", cause).Warn("Session with Yaks lost: reconnecting")
+	y.state = StateReconnecting
+	close(y.lost)
+	y.mu.Unlock()
+	y.notifyState(StateConnected, StateReconnecting, cause)
+	go y.reconnect()
+}
+
+// keepAlive pings the current Transport every period (if it's a Pinger) until Logout,
+// considering the session as lost if it doesn't respond.
+func (y *Yaks) keepAlive(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-y.loggedOut:
+			return
+		}
+		t, state := y.sessionState()
+		if state != StateConnected {
+			continue
+		}
+		p, ok := t.(Pinger)
+		if !ok {
+			return
+		}
+		if err := p.Ping(period); err != nil {
+			y.transportFailed(t, &YError{Op: "Ping", msg: "no response from Yaks", cause: err})
+		}
+	}
+}
+
+// reconnect tries to re-establish the session until it succeeds or Logout is called.
+// Each round tries all the locators, starting with the last one that worked.
+func (y *Yaks) reconnect() {
+	delay := y.retry.MinDelay
+	for {
+		time.Sleep(delay)
+		if y.State() == StateClosed {
+			return
+		}
+
+		y.mu.RLock()
+		start := y.locatorIdx
+		y.mu.RUnlock()
+		logger.Debug("Reconnecting to Yaks")
+		t, idx, err := y.dial(start)
+		if err == nil {
+			err = y.restore(t, idx)
+		}
+		if err == nil {
+			logger.WithField("locator", y.Locator()).Info("Session with Yaks re-established")
+			y.notifyState(StateReconnecting, StateConnected, nil)
+			return
+		}
+		delay = y.retry.nextDelay(delay)
+		logger.WithFields(log.Fields{
+			"error": err,
+			"retry": delay,
+		}).Debug("Reconnection to Yaks failed")
+	}
+}
+
+// restore re-declares the subscriptions and evals of all the Workspaces on the new Transport t
+// (established with the locator at index locatorIdx) and makes t the current Transport.
+func (y *Yaks) restore(t Transport, locatorIdx int) error {
+	yaksid, err := getYaksID(t)
+	if err != nil {
+		t.Close()
+		return err
+	}
+	y.mu.RLock()
+	workspaces := y.workspaces
+	y.mu.RUnlock()
+	for _, w := range workspaces {
+		if err := w.redeclare(t); err != nil {
+			t.Close()
+			return err
+		}
+	}
+
+	y.mu.Lock()
+	if y.state == StateClosed {
+		y.mu.Unlock()
+		t.Close()
+		return ErrClosed
+	}
+	old := y.transport
+	y.transport = t
+	y.locatorIdx = locatorIdx
+	y.yaksid = yaksid
+	y.state = StateConnected
+	y.lost = make(chan struct{})
+	y.mu.Unlock()
+	old.Close()
+	for _, w := range workspaces {
+		w.restored(t)
 	}
 	return nil
 }
 
+func (y *Yaks) newWorkspace(path *Path, useSubroutine bool) *Workspace {
+	y.mu.Lock()
+	w := newWorkspace(path, y, useSubroutine, y.maxInFlight)
+	defer y.mu.Unlock()
+	y.workspaces = append(y.workspaces, w)
+	return w
+}
+
+// removeWorkspace stops tracking w, returning false if it was not tracked (i.e. already closed)
+func (y *Yaks) removeWorkspace(w *Workspace) bool {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	for i, ws := range y.workspaces {
+		if ws == w {
+			// copy, as the slice may be iterated by restore or Logout
+			y.workspaces = append(append([]*Workspace(nil), y.workspaces[:i]...), y.workspaces[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
 // Workspace creates a Workspace using the provided path.
 // All relative Selector or Path used with this Workspace will be relative to this path.
 // Notice that all subscription listeners and eval callbacks declared in this workspace will be
 // executed by the I/O subroutine. This implies that no long operations or other call to Yaks
 // shall be performed in those callbacks.
+// The Workspace is tracked by Yaks (to re-declare its subscriptions and evals after a reconnection)
+// until it's closed, with Close or Logout.
+// If the session was established with a Config enabling the Executor, the Workspace behaves as
+// if created with WorkspaceWithExecutor.
 func (y *Yaks) Workspace(path *Path) *Workspace {
-	return &Workspace{path, y.zenoh, make(map[Path]*zenoh.Eval), false}
+	return y.newWorkspace(path, y.executor)
 }
 
 // WorkspaceWithExecutor creates a Workspace using the provided path.
@@ -99,7 +574,7 @@ func (y *Yaks) Workspace(path *Path) *Workspace {
 // executed by their own subroutine. This is useful when listeners and/or callbacks need to perform
 // long operations or need to call other Yaks operations.
 func (y *Yaks) WorkspaceWithExecutor(path *Path) *Workspace {
-	return &Workspace{path, y.zenoh, make(map[Path]*zenoh.Eval), true}
+	return y.newWorkspace(path, true)
 }
 
 // Admin returns the admin interface
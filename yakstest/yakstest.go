@@ -0,0 +1,355 @@
+// Package yakstest provides a fake Yaks session for testing the users of Workspace with plain `go test`.
+// The session is connected to an in-process memory.Engine acting as a fake router. It records all the
+// puts, updates, removes and queries that cross it, and offers assertions on this traffic.
+// The contents of storages and the responses of evals can be scripted.
+package yakstest
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/memory"
+)
+
+// DefaultTimeout is the default duration AwaitChange waits for a change.
+const DefaultTimeout = time.Second
+
+// OpKind is the kind of a recorded operation
+type OpKind uint8
+
+const (
+	// OpPut is a put
+	OpPut OpKind = iota
+	// OpUpdate is an update
+	OpUpdate
+	// OpRemove is a remove
+	OpRemove
+	// OpQuery is a query (i.e. a get)
+	OpQuery
+)
+
+// String returns the OpKind as a string
+func (k OpKind) String() string {
+	switch k {
+	case OpPut:
+		return "Put"
+	case OpUpdate:
+		return "Update"
+	case OpRemove:
+		return "Remove"
+	case OpQuery:
+		return "Query"
+	default:
+		return "Unknown"
+	}
+}
+
+// Op is an operation recorded by a Session
+type Op struct {
+	Kind OpKind
+	// Path is the path of a write, or the path of the selector of a query
+	Path string
+	// Value is the value of a put or an update (nil otherwise)
+	Value yaks.Value
+	// Predicate is the optional part of the selector of a query (empty otherwise)
+	Predicate string
+	// BatchID is the id of the batch of a write made with Workspace.PutBatch (empty otherwise)
+	BatchID string
+	// Time is the time at which the operation was recorded
+	Time time.Time
+}
+
+// Session is a fake Yaks session. Its assertions report failures to the testing.TB it was created with.
+type Session struct {
+	// Timeout is the duration AwaitChange waits for a change (DefaultTimeout by default).
+	Timeout time.Duration
+
+	tb      testing.TB
+	engine  *memory.Engine
+	router  yaks.Transport
+	y       *yaks.Yaks
+	mu      sync.Mutex
+	ops     []Op
+	awaited map[int]bool
+	changed chan struct{}
+}
+
+// New returns a new Session connected to a new memory.Engine.
+func New(tb testing.TB) *Session {
+	tb.Helper()
+	s := &Session{
+		Timeout: DefaultTimeout,
+		tb:      tb,
+		engine:  memory.NewEngine(),
+		awaited: make(map[int]bool),
+		changed: make(chan struct{}),
+	}
+	s.router = s.engine.NewTransport()
+	y, err := yaks.NewWithTransport(&recorder{s.engine.NewTransport(), s})
+	if err != nil {
+		tb.Fatalf("yakstest: failed to create the session: %v", err)
+	}
+	s.y = y
+	return s
+}
+
+// Yaks returns the Yaks of the Session.
+func (s *Session) Yaks() *yaks.Yaks {
+	return s.y
+}
+
+// Engine returns the memory.Engine the Session is connected to.
+func (s *Session) Engine() *memory.Engine {
+	return s.engine
+}
+
+// Workspace returns a new Workspace on path (the test fails if path is invalid).
+func (s *Session) Workspace(path string) *yaks.Workspace {
+	s.tb.Helper()
+	p, err := yaks.NewPath(path)
+	if err != nil {
+		s.tb.Fatalf("yakstest: %v", err)
+	}
+	return s.y.Workspace(p)
+}
+
+// Close logs out the Session.
+func (s *Session) Close() {
+	s.tb.Helper()
+	if err := s.y.Logout(); err != nil {
+		s.tb.Errorf("yakstest: %v", err)
+	}
+	s.router.Close()
+}
+
+//
+// Recorded traffic
+//
+
+// recorder is a Transport recording the writes and queries into its Session
+type recorder struct {
+	yaks.Transport
+	s *Session
+}
+
+func (r *recorder) WriteDataWO(path string, payload []byte, encoding yaks.Encoding, kind yaks.ChangeKind) error {
+	r.s.record(opOfWrite(path, payload, encoding, kind))
+	return r.Transport.WriteDataWO(path, payload, encoding, kind)
+}
+
+func (r *recorder) WriteBatch(batchID string, samples []yaks.Sample) error {
+	for _, s := range samples {
+		op := opOfWrite(s.Path, s.Data, s.Encoding, s.Kind)
+		op.BatchID = batchID
+		r.s.record(op)
+	}
+	return r.Transport.WriteBatch(batchID, samples)
+}
+
+// MergesUpdates returns true: the storages of the Session are memory storages, which merge the updates
+func (r *recorder) MergesUpdates() bool {
+	return true
+}
+
+func opOfWrite(path string, payload []byte, encoding yaks.Encoding, kind yaks.ChangeKind) Op {
+	op := Op{Path: path}
+	switch kind {
+	case yaks.PUT:
+		op.Kind = OpPut
+	case yaks.UPDATE:
+		op.Kind = OpUpdate
+	default:
+		op.Kind = OpRemove
+	}
+	if op.Kind != OpRemove {
+		value, err := yaks.DecodeValue(encoding, payload)
+		if err != nil {
+			value = yaks.NewRawValue(payload)
+		}
+		op.Value = value
+	}
+	return op
+}
+
+func (r *recorder) Query(path string, predicate string, handler yaks.ReplyHandler) error {
+	r.s.record(Op{Kind: OpQuery, Path: path, Predicate: predicate})
+	return r.Transport.Query(path, predicate, handler)
+}
+
+func (s *Session) record(op Op) {
+	op.Time = time.Now()
+	s.mu.Lock()
+	s.ops = append(s.ops, op)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// Ops returns all the operations recorded since the Session creation or the last Reset.
+func (s *Session) Ops() []Op {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ops := make([]Op, len(s.ops))
+	copy(ops, s.ops)
+	return ops
+}
+
+// Reset forgets all the recorded operations.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	s.ops = nil
+	s.awaited = make(map[int]bool)
+	s.mu.Unlock()
+}
+
+//
+// Assertions
+//
+
+// AssertPut checks that a put of value on path has been recorded.
+func (s *Session) AssertPut(path string, value yaks.Value) {
+	s.tb.Helper()
+	s.assertWrite(OpPut, path, value)
+}
+
+// AssertUpdate checks that an update of path with value has been recorded.
+func (s *Session) AssertUpdate(path string, value yaks.Value) {
+	s.tb.Helper()
+	s.assertWrite(OpUpdate, path, value)
+}
+
+// AssertRemove checks that a remove of path has been recorded.
+func (s *Session) AssertRemove(path string) {
+	s.tb.Helper()
+	s.assertWrite(OpRemove, path, nil)
+}
+
+func (s *Session) assertWrite(kind OpKind, path string, value yaks.Value) {
+	s.tb.Helper()
+	for _, op := range s.Ops() {
+		if op.Kind == kind && op.Path == path && equalValues(op.Value, value) {
+			return
+		}
+	}
+	if value == nil {
+		s.tb.Errorf("yakstest: no %s on %s recorded", kind, path)
+	} else {
+		s.tb.Errorf("yakstest: no %s of '%s' on %s recorded", kind, value.ToString(), path)
+	}
+}
+
+// AssertQuery checks that a query with selector has been recorded.
+func (s *Session) AssertQuery(selector string) {
+	s.tb.Helper()
+	sel, err := yaks.NewSelector(selector)
+	if err != nil {
+		s.tb.Fatalf("yakstest: %v", err)
+	}
+	for _, op := range s.Ops() {
+		if op.Kind == OpQuery && op.Path == sel.Path() && op.Predicate == sel.OptionalPart() {
+			return
+		}
+	}
+	s.tb.Errorf("yakstest: no Query on %s recorded", selector)
+}
+
+// AwaitChange waits for a put, update or remove on a path matching selector and returns it.
+// Each recorded operation is returned at most once by AwaitChange, allowing to await successive changes.
+// The test fails if no such operation is recorded within the Session's Timeout.
+// As it might call FailNow, AwaitChange must be called from the goroutine running the test.
+func (s *Session) AwaitChange(selector string) Op {
+	s.tb.Helper()
+	sel, err := yaks.NewSelector(selector)
+	if err != nil {
+		s.tb.Fatalf("yakstest: %v", err)
+	}
+	timer := time.NewTimer(s.Timeout)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		for i, op := range s.ops {
+			if op.Kind != OpQuery && !s.awaited[i] && memory.Intersect(op.Path, sel.Path()) {
+				s.awaited[i] = true
+				s.mu.Unlock()
+				return op
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			s.tb.Fatalf("yakstest: no change on %s within %v", selector, s.Timeout)
+			return Op{}
+		}
+	}
+}
+
+func equalValues(a yaks.Value, b yaks.Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a.Encoding() != b.Encoding() {
+		return false
+	}
+	// compare the decoded values, as some encodings are not canonical (e.g. PROPERTIES)
+	da, erra := yaks.DecodeValue(a.Encoding(), a.Encode())
+	db, errb := yaks.DecodeValue(b.Encoding(), b.Encode())
+	if erra != nil || errb != nil {
+		return bytes.Equal(a.Encode(), b.Encode())
+	}
+	return reflect.DeepEqual(da, db)
+}
+
+//
+// Scripting
+//
+
+// SetStorage adds a storage with id stid and selector in the fake router, and fills it with contents
+// (i.e. a map of paths to values). These writes are not recorded, but are received by the matching subscribers.
+func (s *Session) SetStorage(stid string, selector string, contents map[string]yaks.Value) {
+	s.tb.Helper()
+	stPath := "/@/" + s.engine.ID() + "/plugins/yaks/backend/" + memory.BackendID + "/storage/" + stid
+	props := yaks.NewPropertiesValue(yaks.Properties{"selector": selector})
+	s.write(stPath, props, yaks.PUT)
+	for path, value := range contents {
+		s.write(path, value, yaks.PUT)
+	}
+}
+
+func (s *Session) write(path string, value yaks.Value, kind yaks.ChangeKind) {
+	s.tb.Helper()
+	if err := s.router.WriteDataWO(path, value.Encode(), value.Encoding(), kind); err != nil {
+		s.tb.Fatalf("yakstest: failed to write %s: %v", path, err)
+	}
+}
+
+// ScriptEval registers an eval on path in the fake router. The successive queries on path
+// get the successive responses, the last response being repeated for the following queries.
+// With no responses, the eval replies nothing.
+func (s *Session) ScriptEval(path string, responses ...yaks.Value) {
+	s.tb.Helper()
+	var mu sync.Mutex
+	i := 0
+	_, err := s.router.DeclareEval(path, func(rname string, predicate string, sendReplies yaks.RepliesSender) {
+		if len(responses) == 0 {
+			sendReplies(nil)
+			return
+		}
+		mu.Lock()
+		value := responses[i]
+		if i < len(responses)-1 {
+			i++
+		}
+		mu.Unlock()
+		sendReplies([]yaks.Sample{{Path: path, Data: value.Encode(), Encoding: value.Encoding(), Kind: yaks.PUT}})
+	})
+	if err != nil {
+		s.tb.Fatalf("yakstest: failed to register eval on %s: %v", path, err)
+	}
+}
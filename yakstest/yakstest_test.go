@@ -0,0 +1,158 @@
+package yakstest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atolab/yaks-go"
+)
+
+// fakeTB records the failures reported by a Session, instead of failing the test
+type fakeTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func path(t *testing.T, s string) *yaks.Path {
+	t.Helper()
+	p, err := yaks.NewPath(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRecordedWrites(t *testing.T) {
+	s := New(t)
+	defer s.Close()
+	w := s.Workspace("/home")
+	if err := w.Put(path(t, "temp"), yaks.NewStringValue("21")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Update(path(t, "conf"), yaks.NewPropertiesValue(yaks.Properties{"a": "1", "b": "2"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Remove(path(t, "temp")); err != nil {
+		t.Fatal(err)
+	}
+
+	s.AssertPut("/home/temp", yaks.NewStringValue("21"))
+	// the PROPERTIES values are compared once decoded
+	s.AssertUpdate("/home/conf", yaks.NewPropertiesValue(yaks.Properties{"b": "2", "a": "1"}))
+	s.AssertRemove("/home/temp")
+
+	ops := s.Ops()
+	if len(ops) != 3 || ops[0].Kind != OpPut || ops[1].Kind != OpUpdate || ops[2].Kind != OpRemove {
+		t.Errorf("recorded ops = %+v", ops)
+	}
+	s.Reset()
+	if len(s.Ops()) != 0 {
+		t.Errorf("ops recorded after Reset: %+v", s.Ops())
+	}
+}
+
+func TestAssertionFailures(t *testing.T) {
+	tb := &fakeTB{TB: t}
+	s := New(tb)
+	defer s.Close()
+	w := s.Workspace("/")
+	w.Put(path(t, "/a"), yaks.NewStringValue("1"))
+
+	s.AssertPut("/a", yaks.NewStringValue("2"))
+	s.AssertPut("/b", yaks.NewStringValue("1"))
+	s.AssertUpdate("/a", yaks.NewStringValue("1"))
+	s.AssertRemove("/a")
+	s.AssertQuery("/a")
+	want := []string{
+		"no Put of '2' on /a",
+		"no Put of '1' on /b",
+		"no Update of '1' on /a",
+		"no Remove on /a",
+		"no Query on /a",
+	}
+	if len(tb.errors) != len(want) {
+		t.Fatalf("failures = %q, want %d failures", tb.errors, len(want))
+	}
+	for i, e := range tb.errors {
+		if !strings.Contains(e, want[i]) {
+			t.Errorf("failure %d = %q, want %q", i, e, want[i])
+		}
+	}
+}
+
+func TestSetStorageAndQuery(t *testing.T) {
+	s := New(t)
+	defer s.Close()
+	s.SetStorage("st", "/a/**", map[string]yaks.Value{
+		"/a/x": yaks.NewStringValue("1"),
+		"/a/y": yaks.NewStringValue("2"),
+	})
+	w := s.Workspace("/a")
+	sel, _ := yaks.NewSelector("/a/*?(x>1)")
+	entries, err := w.GetWithOptions(sel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Value().ToString() != "1" || entries[1].Value().ToString() != "2" {
+		t.Errorf("entries = %v", entries)
+	}
+	s.AssertQuery("/a/*?(x>1)")
+	// the writes of SetStorage are not recorded
+	for _, op := range s.Ops() {
+		if op.Kind != OpQuery {
+			t.Errorf("recorded op %+v", op)
+		}
+	}
+}
+
+func TestScriptEval(t *testing.T) {
+	s := New(t)
+	defer s.Close()
+	s.ScriptEval("/e", yaks.NewStringValue("first"), yaks.NewStringValue("next"))
+	s.ScriptEval("/none")
+	w := s.Workspace("/")
+	sel, _ := yaks.NewSelector("/e")
+	for _, want := range []string{"first", "next", "next"} {
+		entries, err := w.GetWithOptions(sel, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Value().ToString() != want {
+			t.Errorf("eval replied %v, want %s", entries, want)
+		}
+	}
+	sel, _ = yaks.NewSelector("/none")
+	if entries, _ := w.GetWithOptions(sel, nil); len(entries) != 0 {
+		t.Errorf("eval without responses replied %v", entries)
+	}
+}
+
+func TestAwaitChange(t *testing.T) {
+	s := New(t)
+	defer s.Close()
+	s.Timeout = 5 * time.Second
+	w := s.Workspace("/")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		w.Put(path(t, "/a/x"), yaks.NewStringValue("1"))
+		w.PutBatch(yaks.NewBatch().Put(path(t, "/a/y"), yaks.NewStringValue("2")).Remove(path(t, "/b")))
+	}()
+	if op := s.AwaitChange("/a/*"); op.Path != "/a/x" || op.Kind != OpPut {
+		t.Errorf("first change = %+v", op)
+	}
+	op := s.AwaitChange("/a/*")
+	if op.Path != "/a/y" || op.BatchID == "" {
+		t.Errorf("second change = %+v, want /a/y in a batch", op)
+	}
+	if op := s.AwaitChange("/b"); op.Kind != OpRemove {
+		t.Errorf("third change = %+v", op)
+	}
+}
@@ -0,0 +1,125 @@
+package zenohtransport
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/yaks-go/memory"
+)
+
+// batchEncoding is the encoding of the samples written by WriteBatch: their data is an envelope wrapping
+// the encoding and data of the sample, with the id of the batch and the paths of all its samples.
+const batchEncoding yaks.Encoding = 0xb0
+
+var errInvalidEnvelope = errors.New("invalid batch envelope")
+
+// envelope is the data of a sample written by WriteBatch
+type envelope struct {
+	batchID string
+	// paths of all the samples of the batch
+	paths    []string
+	encoding yaks.Encoding
+	data     []byte
+}
+
+func appendString(buf []byte, s string) []byte {
+	var n [binary.MaxVarintLen64]byte
+	buf = append(buf, n[:binary.PutUvarint(n[:], uint64(len(s)))]...)
+	return append(buf, s...)
+}
+
+// encode returns the envelope as: the batch id, the number of paths, the paths (each string prefixed
+// by its length as an uvarint), the encoding and the data.
+func (e *envelope) encode() []byte {
+	var n [binary.MaxVarintLen64]byte
+	buf := appendString(nil, e.batchID)
+	buf = append(buf, n[:binary.PutUvarint(n[:], uint64(len(e.paths)))]...)
+	for _, p := range e.paths {
+		buf = appendString(buf, p)
+	}
+	buf = append(buf, e.encoding)
+	return append(buf, e.data...)
+}
+
+func readString(buf []byte) (string, []byte, error) {
+	l, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < l {
+		return "", nil, errInvalidEnvelope
+	}
+	return string(buf[n : n+int(l)]), buf[n+int(l):], nil
+}
+
+func decodeEnvelope(buf []byte) (*envelope, error) {
+	e := new(envelope)
+	var err error
+	if e.batchID, buf, err = readString(buf); err != nil {
+		return nil, err
+	}
+	count, n := binary.Uvarint(buf)
+	// each path takes at least 1 byte
+	if n <= 0 || uint64(len(buf)-n) < count {
+		return nil, errInvalidEnvelope
+	}
+	buf = buf[n:]
+	e.paths = make([]string, count)
+	for i := range e.paths {
+		if e.paths[i], buf, err = readString(buf); err != nil {
+			return nil, err
+		}
+	}
+	if len(buf) == 0 {
+		return nil, errInvalidEnvelope
+	}
+	e.encoding = buf[0]
+	e.data = buf[1:]
+	return e, nil
+}
+
+// matching returns the number of paths of the batch that match selector
+func (e *envelope) matching(selector string) int {
+	count := 0
+	for _, p := range e.paths {
+		if memory.Intersect(p, selector) {
+			count++
+		}
+	}
+	return count
+}
+
+// unwrapSample replaces the data and encoding of a sample received by a subscriber on selector
+// with the ones of its envelope, and sets its BatchID and BatchSize (the number of samples of the
+// batch matching selector). A sample not written by WriteBatch is left unchanged.
+func unwrapSample(sample *yaks.Sample, selector string) error {
+	if sample.Encoding != batchEncoding {
+		return nil
+	}
+	e, err := decodeEnvelope(sample.Data)
+	if err != nil {
+		return err
+	}
+	sample.Data = e.data
+	sample.Encoding = e.encoding
+	sample.BatchID = e.batchID
+	sample.BatchSize = e.matching(selector)
+	if sample.BatchSize == 0 {
+		// selector isn't a path expression memory.Intersect knows
+		sample.BatchSize = 1
+	}
+	return nil
+}
+
+// unwrapReply replaces the data and encoding of a data reply with the ones of its envelope,
+// if it has been written by WriteBatch.
+func unwrapReply(reply *yaks.Reply) error {
+	if reply.Encoding != batchEncoding {
+		return nil
+	}
+	e, err := decodeEnvelope(reply.Data)
+	if err != nil {
+		return err
+	}
+	reply.Data = e.data
+	reply.Encoding = e.encoding
+	return nil
+}
@@ -0,0 +1,49 @@
+package zenohtransport
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/atolab/yaks-go"
+)
+
+func TestEnvelope(t *testing.T) {
+	e := &envelope{"batch", []string{"/a/x", "/a/y", "/b/x"}, yaks.Encoding(2), []byte("data")}
+	got, err := decodeEnvelope(e.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("decodeEnvelope(encode()) = %+v, want %+v", got, e)
+	}
+	for _, buf := range [][]byte{nil, {5, 'b'}, {0, 3, 1, 'p'}, e.encode()[:12]} {
+		if _, err := decodeEnvelope(buf); err == nil {
+			t.Errorf("decodeEnvelope(%v) succeeded", buf)
+		}
+	}
+}
+
+func TestUnwrapSample(t *testing.T) {
+	e := &envelope{"batch", []string{"/a/x", "/a/y", "/b/x"}, yaks.Encoding(2), []byte("data")}
+	for _, tt := range []struct {
+		selector string
+		size     int
+	}{
+		{"/a/*", 2},
+		{"/**", 3},
+		{"/b/x", 1},
+	} {
+		s := &yaks.Sample{Path: "/a/x", Data: e.encode(), Encoding: batchEncoding}
+		if err := unwrapSample(s, tt.selector); err != nil {
+			t.Fatal(err)
+		}
+		if s.BatchID != "batch" || s.BatchSize != tt.size || string(s.Data) != "data" || s.Encoding != 2 {
+			t.Errorf("unwrapSample(%s) = %+v, want a batch of %d", tt.selector, s, tt.size)
+		}
+	}
+
+	s := &yaks.Sample{Path: "/a/x", Data: []byte("data"), Encoding: 2}
+	if err := unwrapSample(s, "/a/*"); err != nil || s.BatchID != "" || s.BatchSize != 0 || string(s.Data) != "data" {
+		t.Errorf("unwrapSample of a sample not in a batch = %+v, %v", s, err)
+	}
+}
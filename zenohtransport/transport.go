@@ -0,0 +1,153 @@
+package zenohtransport
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/zenoh-go"
+)
+
+// transport is the yaks.Transport implementation using a Zenoh session
+type transport struct {
+	z *zenoh.Zenoh
+}
+
+// NewTransport returns a yaks.Transport using the Zenoh session z.
+func NewTransport(z *zenoh.Zenoh) yaks.Transport {
+	return &transport{z}
+}
+
+func timestampOfZenoh(ts *zenoh.Timestamp) yaks.Timestamp {
+	return yaks.NewNTPTimestamp(ts.Time(), ts.ClockID())
+}
+
+func (t *transport) WriteDataWO(path string, payload []byte, encoding yaks.Encoding, kind yaks.ChangeKind) error {
+	return t.z.WriteDataWO(path, payload, encoding, kind)
+}
+
+// WriteBatch writes the samples one after the other, as Zenoh has no batch write: each one is wrapped in
+// an envelope with the batch id and the paths of the batch (see batchEncoding). The subscribers and queries
+// of this package unwrap it, and the subscribers receive the samples of the batch matching their selector
+// with their BatchID and BatchSize. Notice that each sample gets its own timestamp, and that the other
+// Zenoh clients get the envelopes as is.
+func (t *transport) WriteBatch(batchID string, samples []yaks.Sample) error {
+	paths := make([]string, len(samples))
+	for i := range samples {
+		paths[i] = samples[i].Path
+	}
+	for _, s := range samples {
+		e := &envelope{batchID, paths, s.Encoding, s.Data}
+		if err := t.z.WriteDataWO(s.Path, e.encode(), batchEncoding, s.Kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *transport) Query(path string, predicate string, handler yaks.ReplyHandler) error {
+	return t.z.Query(path, predicate, func(reply *zenoh.ReplyValue) {
+		r := new(yaks.Reply)
+		switch reply.Kind() {
+		case zenoh.ZStorageData, zenoh.ZEvalData:
+			if reply.Kind() == zenoh.ZStorageData {
+				r.Kind = yaks.ReplyStorageData
+			} else {
+				r.Kind = yaks.ReplyEvalData
+			}
+			info := reply.Info()
+			ts := info.Tstamp()
+			r.SourceID = reply.SrcID()
+			r.Path = reply.RName()
+			r.Data = reply.Data()
+			r.Encoding = info.Encoding()
+			r.Timestamp = timestampOfZenoh(&ts)
+			if err := unwrapReply(r); err != nil {
+				// passed as is, to be reported by the Workspace as undecodable
+				r.Encoding = batchEncoding
+			}
+		case zenoh.ZStorageFinal:
+			r.Kind = yaks.ReplyStorageFinal
+			r.SourceID = reply.SrcID()
+		case zenoh.ZEvalFinal:
+			r.Kind = yaks.ReplyEvalFinal
+			r.SourceID = reply.SrcID()
+		case zenoh.ZReplyFinal:
+			r.Kind = yaks.ReplyFinal
+		default:
+			return
+		}
+		handler(r)
+	})
+}
+
+func (t *transport) DeclareSubscriber(path string, handler yaks.SampleHandler) (yaks.TransportSubscriber, error) {
+	return t.z.DeclareSubscriber(path, zenoh.NewSubMode(zenoh.ZPushMode), func(rid string, data []byte, info *zenoh.DataInfo) {
+		ts := info.Tstamp()
+		sample := &yaks.Sample{Path: rid, Data: data, Encoding: info.Encoding(), Kind: info.Kind(), Timestamp: timestampOfZenoh(&ts)}
+		// an invalid envelope is passed as is, to be reported by the Workspace as undecodable
+		unwrapSample(sample, path)
+		handler(sample)
+	})
+}
+
+func (t *transport) UndeclareSubscriber(sub yaks.TransportSubscriber) error {
+	return t.z.UndeclareSubscriber(sub.(*zenoh.Subscriber))
+}
+
+func (t *transport) DeclareEval(path string, handler yaks.QueryHandler) (yaks.TransportEval, error) {
+	return t.z.DeclareEval(path, func(rname string, predicate string, repliesSender *zenoh.RepliesSender) {
+		handler(rname, predicate, func(replies []yaks.Sample) {
+			resources := make([]zenoh.Resource, len(replies))
+			for i, r := range replies {
+				resources[i] = zenoh.Resource{RName: r.Path, Data: r.Data, Encoding: r.Encoding, Kind: r.Kind}
+			}
+			repliesSender.SendReplies(resources)
+		})
+	})
+}
+
+func (t *transport) UndeclareEval(eval yaks.TransportEval) error {
+	return t.z.UndeclareEval(eval.(*zenoh.Eval))
+}
+
+// Ping queries the admin space of the Yaks the session is connected to,
+// and returns an error if the final reply isn't received within timeout.
+func (t *transport) Ping(timeout time.Duration) error {
+	final := make(chan struct{})
+	var once sync.Once
+	err := t.z.Query("/@/"+t.Info().YaksID, "", func(reply *zenoh.ReplyValue) {
+		if reply.Kind() == zenoh.ZReplyFinal {
+			once.Do(func() { close(final) })
+		}
+	})
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-final:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("no reply to ping after %s", timeout)
+	}
+}
+
+func (t *transport) Info() *yaks.SessionInfo {
+	props := t.z.Info()
+	info := new(yaks.SessionInfo)
+	info.SessionID = hex.EncodeToString(props[zenoh.ZInfoPidKey])
+	if pid, ok := props[zenoh.ZInfoPeerPidKey]; ok {
+		info.YaksID = hex.EncodeToString(pid)
+	}
+	info.Locator = strings.TrimRight(string(props[zenoh.ZInfoPeerKey]), "\x00")
+	return info
+}
+
+func (t *transport) Close() error {
+	return t.z.Close()
+}
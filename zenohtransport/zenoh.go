@@ -0,0 +1,46 @@
+// Package zenohtransport provides the Zenoh transport of the Yaks client API. It requires cgo and the zenoh-c
+// library: the yaks package itself doesn't depend on it, so that it can be used without Zenoh (e.g. in tests,
+// with the memory package).
+//
+// Importing this package sets its Dialer as the default one of yaks.Login, yaks.LoginWithOptions and
+// yaks.Config.Login, typically with a blank import:
+//
+//	import (
+//	    "github.com/atolab/yaks-go"
+//	    _ "github.com/atolab/yaks-go/zenohtransport"
+//	)
+//
+//	y, err := yaks.Login(locator, nil)
+package zenohtransport
+
+import (
+	"github.com/atolab/yaks-go"
+	"github.com/atolab/zenoh-go"
+)
+
+func init() {
+	yaks.SetDefaultDialer(Dial)
+}
+
+// Dial is the yaks.Dialer establishing a Zenoh session with the Yaks instance reachable via locator
+// (or via some dynamic discovery if locator is nil).
+func Dial(locator *string, properties yaks.Properties) (yaks.Transport, error) {
+	z, err := zenoh.ZOpen(locator, getZProps(properties))
+	if err != nil {
+		return nil, err
+	}
+	return NewTransport(z), nil
+}
+
+func getZProps(properties yaks.Properties) map[int][]byte {
+	zprops := make(map[int][]byte)
+	user, ok := properties[yaks.PropUser]
+	if ok {
+		zprops[zenoh.ZUserKey] = []byte(user)
+	}
+	password, ok := properties[yaks.PropPassword]
+	if ok {
+		zprops[zenoh.ZPasswdKey] = []byte(password)
+	}
+	return zprops
+}